@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Default bounds of --poll-interval adaptive.
+const (
+	defaultAdaptiveMin = 100 * time.Millisecond
+	defaultAdaptiveMax = 5 * time.Second
+)
+
+// pollIntervalFlag is the flag.Value of --poll-interval. It accepts a duration, or the keyword
+// adaptive with optional bounds, e.g. adaptive:min=100ms,max=5s. For adaptive polling, pollInterval
+// holds the lower bound and adaptiveMax the upper one; adaptiveMax is 0 for a fixed interval.
+type pollIntervalFlag struct {
+	a *args
+}
+
+// String returns the flag value in the form it is parsed from.
+func (f pollIntervalFlag) String() string {
+	if f.a == nil {
+		return ""
+	}
+	if f.a.adaptiveMax > 0 {
+		return fmt.Sprintf("adaptive:min=%v,max=%v", f.a.pollInterval, f.a.adaptiveMax)
+	}
+	return f.a.pollInterval.String()
+}
+
+// Set parses a duration or an adaptive specification.
+func (f pollIntervalFlag) Set(v string) error {
+	if v != "adaptive" && !strings.HasPrefix(v, "adaptive:") {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.a.pollInterval, f.a.adaptiveMax = d, 0
+		return nil
+	}
+
+	lower, upper := defaultAdaptiveMin, defaultAdaptiveMax
+	if params, ok := strings.CutPrefix(v, "adaptive:"); ok {
+		for _, param := range strings.Split(params, ",") {
+			key, value, _ := strings.Cut(param, "=")
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("adaptive %s: %w", key, err)
+			}
+			switch key {
+			case "min":
+				lower = d
+			case "max":
+				upper = d
+			default:
+				return fmt.Errorf("unknown adaptive parameter %q, expected min or max", key)
+			}
+		}
+	}
+	if upper < lower {
+		return fmt.Errorf("adaptive max %v is smaller than min %v", upper, lower)
+	}
+	f.a.pollInterval, f.a.adaptiveMax = lower, upper
+	return nil
+}
+
+// pollDue reports whether a file is to be polled in this cycle. With a fixed interval, every file is.
+func (a *app) pollDue(state *fileState, now time.Time) bool {
+	return a.adaptiveMax <= 0 || !now.Before(state.nextPoll)
+}
+
+// schedulePoll sets when a file is polled next with --poll-interval adaptive. The heuristic is a
+// simple feedback loop: a file that had new content is polled again at the lower bound, and each poll
+// without new content doubles its interval, up to the upper bound. Busy files are thus read with low
+// latency, while idle ones cost few stat calls, and a file waking up is caught within the upper bound.
+func (a *app) schedulePoll(state *fileState, hadContent bool, now time.Time) {
+	if a.adaptiveMax <= 0 {
+		return
+	}
+	if hadContent || state.pollEvery <= 0 {
+		state.pollEvery = a.pollInterval
+	} else {
+		state.pollEvery = min(2*state.pollEvery, a.adaptiveMax)
+	}
+	state.nextPoll = now.Add(state.pollEvery)
+}
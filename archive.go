@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// gzipMagic are the first bytes of gzip compressed data, telling a tar.gz from a plain tar.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// readArchive emits the regular members of the --archive tar file, optionally gzip compressed,
+// as if each were a file read from the start with --stop-at-eof. The member name is used as
+// the file path. Only members matching a glob pattern are read, or all if none are given.
+func (a *app) readArchive(ctx context.Context) error {
+	file, err := a.openFile(a.archive)
+	if err != nil {
+		return fmt.Errorf("opening archive: %w", err)
+	}
+	defer a.closeFile(file)
+
+	var r io.Reader = bufio.NewReader(file)
+	if magic, _ := r.(*bufio.Reader).Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("reading archive %s: %w", a.archive, err)
+		}
+		defer func() { _ = zr.Close() }()
+		r = zr
+	}
+
+	defer a.finishOutput()
+	tr := tar.NewReader(r)
+	buf := make([]byte, 64*1024)
+	for ctx.Err() == nil {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive %s: %w", a.archive, err)
+		}
+		name := path.Clean(hdr.Name)
+		pattern, ok := a.archiveMemberPattern(name)
+		if hdr.Typeflag != tar.TypeReg || !ok {
+			continue
+		}
+
+		// Each member has its own state, so lines and context of different members never mix.
+		state := &fileState{pattern: pattern}
+		for ctx.Err() == nil {
+			n, err := tr.Read(buf)
+			data := buf[:n]
+			if a.stripBOM && state.offset == 0 {
+				data = bytes.TrimPrefix(data, utf8BOM)
+			}
+			state.lines.readAt = state.offset + int64(n-len(data))
+			state.offset += int64(n)
+			if len(data) > 0 {
+				a.emitContent(name, state, data)
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("reading %s from archive %s: %w", name, a.archive, err)
+			}
+		}
+		if a.lineMode() {
+			a.flushPartialLine(name, state)
+		}
+	}
+	return nil
+}
+
+// archiveMemberPattern returns the first glob pattern matching the name of an archive member.
+// Without patterns, every member matches.
+func (a *app) archiveMemberPattern(name string) (string, bool) {
+	if len(a.args.patterns) == 0 {
+		return "", true
+	}
+	for _, pattern := range a.args.patterns {
+		if ok, _ := doublestar.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Styles of --batch-markers.
+const (
+	batchNone  = "none"
+	batchText  = "text"
+	batchJSON  = "json"
+	batchBlank = "blank"
+)
+
+// batchMarker is a --batch-markers json marker.
+type batchMarker struct {
+	Batch  uint64    `json:"batch"`
+	Marker string    `json:"marker"`
+	Time   time.Time `json:"time"`
+}
+
+// beginBatch starts the batch of a poll cycle. Its begin marker is only written with the first
+// output of the cycle, see openBatch, so cycles without content write nothing unless --batch-empty is given.
+func (a *app) beginBatch() {
+	a.inBatch = a.batchMarkers != batchNone
+}
+
+// openBatch writes the begin marker of the batch of the current poll cycle, if it is not written yet.
+// It is called before anything is written to the output.
+func (a *app) openBatch() {
+	if !a.inBatch || a.batchOpen {
+		return
+	}
+	a.batchOpen = true
+	a.batchNo++
+	// Each batch names the files of its content, even if the previous one ended with the same file.
+	a.prevPath = ""
+	a.writeBatchMarker("begin")
+}
+
+// endBatch writes the end marker of the batch of a poll cycle, before the output is flushed.
+func (a *app) endBatch() {
+	if !a.inBatch {
+		return
+	}
+	if !a.batchOpen && a.batchEmpty {
+		a.openBatch()
+	}
+	if a.batchOpen {
+		a.writeBatchMarker("end")
+	}
+	a.inBatch, a.batchOpen = false, false
+}
+
+// writeBatchMarker writes a marker of the current batch, in the --batch-markers style:
+//   - text: a line "=== batch N begin ===" or "=== batch N end ===".
+//   - json: an object {"batch":N,"marker":"begin","time":"..."}, or with "marker":"end".
+//   - blank: an empty line after each batch, and nothing before it.
+//
+// N counts the batches written, from 1. Markers end with a NUL byte instead of a newline with --print0.
+func (a *app) writeBatchMarker(marker string) {
+	var b []byte
+	switch a.batchMarkers {
+	case batchText:
+		b = []byte(fmt.Sprintf("=== batch %d %s ===\n", a.batchNo, marker))
+	case batchJSON:
+		b, _ = json.Marshal(batchMarker{Batch: a.batchNo, Marker: marker, Time: time.Now()})
+		b = append(b, '\n')
+	case batchBlank:
+		if marker != "end" {
+			return
+		}
+		b = []byte("\n")
+	}
+	if a.print0 {
+		b[len(b)-1] = 0
+	}
+	_, _ = a.out.Write(b)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Policies of --buffer-memory-policy.
+const (
+	bufferPolicyFlush = "flush"
+	bufferPolicyDrop  = "drop"
+)
+
+// validBufferPolicy checks a --buffer-memory-policy.
+func validBufferPolicy(policy string) error {
+	if policy != bufferPolicyFlush && policy != bufferPolicyDrop {
+		return fmt.Errorf("--buffer-memory-policy must be %s or %s, got %q", bufferPolicyFlush, bufferPolicyDrop, policy)
+	}
+	return nil
+}
+
+// bufferAccountant keeps the memory the watched files buffer between reads within --max-buffer-memory.
+// It is only accessed from the polling goroutine.
+type bufferAccountant struct {
+	// evicted counts the files whose buffers were evicted since lastWarning.
+	evicted     int
+	lastWarning time.Time
+}
+
+// bufferedBytes returns the bytes a file holds between reads: its incomplete last line or
+// --json-array element, its leading context lines, and the content --diff-mode keeps of it.
+func bufferedBytes(state *fileState) int64 {
+	ls := &state.lines
+	n := len(ls.partial) + len(ls.jsonPending) + len(state.diff.content)
+	for _, line := range ls.before {
+		n += len(line)
+	}
+	return int64(n)
+}
+
+// enforceBufferMemory evicts the buffers of the files holding the most, largest first, until all
+// of them together are within --max-buffer-memory again. It is called after each poll cycle read
+// the files. For each file evicted:
+//   - its incomplete last line is output as it is with --buffer-memory-policy flush, and the rest
+//     of it becomes a line of its own, or with drop it is discarded with the rest of it;
+//   - an incomplete --json-array element is discarded, and the rest of it skipped as invalid;
+//   - its leading context lines are discarded;
+//   - --diff-mode stops keeping its content, so it is output in full when replaced.
+//
+// A warning is logged when the budget is exceeded, and then every limitReportInterval while it is.
+func (a *app) enforceBufferMemory(now time.Time) {
+	b := a.bufferMemory
+	if b == nil {
+		return
+	}
+	type buffered struct {
+		path  string
+		state *fileState
+		n     int64
+	}
+	var files []buffered
+	var total int64
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		state := value.(*fileState)
+		if n := bufferedBytes(state); n > 0 {
+			files = append(files, buffered{key.(string), state, n})
+			total += n
+		}
+		return true
+	})
+	if total <= int64(a.maxBufferMemory) {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].n != files[j].n {
+			return files[i].n > files[j].n
+		}
+		return files[i].path < files[j].path
+	})
+	over := total
+	for _, f := range files {
+		if over <= int64(a.maxBufferMemory) {
+			break
+		}
+		a.evictBuffers(f.path, f.state)
+		over -= f.n
+		b.evicted++
+	}
+	if b.lastWarning.IsZero() || now.Sub(b.lastWarning) >= limitReportInterval {
+		log.Printf("Warning: Line buffers reached %s, over --max-buffer-memory; %s the buffers of %d files, largest first.\n", formatBytes(float64(total)), b.verb(a.bufferPolicy), b.evicted)
+		b.evicted, b.lastWarning = 0, now
+	}
+}
+
+// verb describes what the policy does to the buffers, for the warning.
+func (b *bufferAccountant) verb(policy string) string {
+	if policy == bufferPolicyFlush {
+		return "flushed"
+	}
+	return "dropped"
+}
+
+// evictBuffers frees what a file buffers, see enforceBufferMemory.
+func (a *app) evictBuffers(path string, state *fileState) {
+	ls := &state.lines
+	if len(ls.partial) > 0 {
+		if a.bufferPolicy == bufferPolicyFlush {
+			a.flushPartialLine(path, state)
+		} else {
+			ls.partial, ls.skipLine = nil, true
+		}
+	}
+	ls.jsonPending = nil
+	ls.before, ls.beforeAt = nil, nil
+	if len(state.diff.content) > 0 {
+		state.diff.content, state.diff.overflow = nil, true
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// timeFlag is a flag holding a point in time in RFC 3339 format, e.g. 2006-01-02T15:04:05Z07:00.
+// The zero time means not set.
+type timeFlag struct {
+	time.Time
+}
+
+// String returns the time in RFC 3339 format, or an empty string if not set.
+func (f *timeFlag) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return f.Format(time.RFC3339)
+}
+
+// Set parses an RFC 3339 time.
+func (f *timeFlag) Set(v string) error {
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return err
+	}
+	f.Time = t
+	return nil
+}
+
+// modifiedSinceCheckpoint tells, with --checkpoint, whether a file found at startup was modified
+// at or after the checkpoint, and so is read from the start. The modification time only tells
+// that something was written since, not where: the whole file is read, including lines written
+// before the checkpoint. Files not modified since are read from the end.
+func (a *app) modifiedSinceCheckpoint(fi os.FileInfo) bool {
+	return !a.checkpoint.IsZero() && !fi.ModTime().Before(a.checkpoint.Time)
+}
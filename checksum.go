@@ -0,0 +1,24 @@
+package main
+
+import (
+	"hash/crc32"
+	"io"
+)
+
+// checksumHeaderSize is the size of the region at the start of a file checked by --checksum-verify.
+const checksumHeaderSize = 256
+
+// headerChanged reports whether the start of a file differs from when it was last polled,
+// which means it was replaced by another file of at least the same size, with --checksum-verify.
+// It records the checksum of up to checksumHeaderSize bytes at the start of the file in state.
+// A start shorter than the recorded one is left to the truncation check.
+func headerChanged(file io.ReaderAt, state *fileState) bool {
+	buf := make([]byte, checksumHeaderSize)
+	n, _ := file.ReadAt(buf, 0)
+	buf = buf[:n]
+
+	changed := state.headerLen > 0 && n >= state.headerLen &&
+		crc32.ChecksumIEEE(buf[:state.headerLen]) != state.headerSum
+	state.headerLen, state.headerSum = n, crc32.ChecksumIEEE(buf)
+	return changed
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// Values of --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// Escape sequences around highlighted --include matches: bold and reverse video, then a reset.
+const (
+	highlightStart = "\x1b[1;7m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// validColor checks a --color value.
+func validColor(v string) error {
+	switch v {
+	case colorAuto, colorAlways, colorNever:
+		return nil
+	}
+	return fmt.Errorf("--color must be %s, %s, or %s, got %q", colorAuto, colorAlways, colorNever, v)
+}
+
+// highlightMatches reports whether the --include matches are highlighted in the output.
+// Only raw and text output can carry escape sequences; with auto, standard output must be
+// a terminal and NO_COLOR must not be set.
+func (a *app) highlightMatches() bool {
+	if len(a.include) == 0 || a.color == colorNever {
+		return false
+	}
+	if (a.format != formatRaw && a.format != "text") || a.framing == framingLength || a.print0 || a.count || a.outputTemplate != "" {
+		return false
+	}
+	if a.color == colorAlways {
+		return true
+	}
+	return os.Getenv("NO_COLOR") == "" && a.output == "" && isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f looks like a terminal, i.e. is a character device.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// newHighlighter creates a lineTransform wrapping the matches of the expressions in highlight
+// escape sequences. It is registered after the other transforms, so it sees the line as output,
+// e.g. with the escape sequences of the file already removed by --strip-ansi. Escape sequences
+// that remain are never split: a highlight is closed before them and reopened after them,
+// and the colors the line set before are restored when a highlight ends.
+func newHighlighter(res regexpList) lineTransform {
+	return func(_ string, line []byte) []byte {
+		marked := make([]bool, len(line))
+		found := false
+		for _, re := range res {
+			for _, m := range re.FindAllIndex(line, -1) {
+				for i := m[0]; i < m[1]; i++ {
+					marked[i] = true
+					found = true
+				}
+			}
+		}
+		if !found {
+			return line
+		}
+
+		escapes := ansiEscape.FindAllIndex(line, -1)
+		var out bytes.Buffer
+		// sgr holds the color sequences of the line since its last reset, to restore after a highlight.
+		var sgr [][]byte
+		on := false
+		for i := 0; i < len(line); {
+			if len(escapes) > 0 && escapes[0][0] == i {
+				if on {
+					out.WriteString(highlightEnd)
+					out.Write(bytes.Join(sgr, nil))
+					on = false
+				}
+				esc := line[i:escapes[0][1]]
+				if bytes.HasSuffix(esc, []byte("m")) && bytes.HasPrefix(esc, []byte("\x1b[")) {
+					if string(esc) == "\x1b[m" || string(esc) == "\x1b[0m" {
+						sgr = nil
+					} else {
+						sgr = append(sgr, esc)
+					}
+				}
+				out.Write(esc)
+				i = escapes[0][1]
+				escapes = escapes[1:]
+				continue
+			}
+			if marked[i] != on {
+				if on {
+					out.WriteString(highlightEnd)
+					out.Write(bytes.Join(sgr, nil))
+				} else {
+					out.WriteString(highlightStart)
+				}
+				on = marked[i]
+			}
+			out.WriteByte(line[i])
+			i++
+		}
+		if on {
+			out.WriteString(highlightEnd)
+			out.Write(bytes.Join(sgr, nil))
+		}
+		return out.Bytes()
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHighlighter(t *testing.T) {
+	// In want, « and » stand for the start and end of a highlight.
+	marks := strings.NewReplacer("«", highlightStart, "»", highlightEnd)
+	tests := []struct {
+		name     string
+		patterns []string
+		line     string
+		want     string
+	}{
+		{"no match", []string{"err"}, "all good", "all good"},
+		{"match at the start", []string{"err"}, "error at start", "«err»or at start"},
+		{"match at the end", []string{"err"}, "an err", "an «err»"},
+		{"whole line", []string{".*"}, "all", "«all»"},
+		{"adjacent matches merge", []string{"a"}, "aa b a", "«aa» b «a»"},
+		{"overlapping patterns merge", []string{"ab", "bc"}, "abcd", "«abc»d"},
+		{
+			name:     "escape inside a match is not highlighted",
+			patterns: []string{`er\S*r`},
+			line:     "er\x1b[31mr",
+			want:     "«er»\x1b[31m«r»\x1b[31m",
+		},
+		{
+			name:     "color restored after a highlight",
+			patterns: []string{"err"},
+			line:     "\x1b[32mok err done",
+			want:     "\x1b[32mok «err»\x1b[32m done",
+		},
+		{
+			name:     "reset clears the color to restore",
+			patterns: []string{"err"},
+			line:     "\x1b[32mok\x1b[0m err",
+			want:     "\x1b[32mok\x1b[0m «err»",
+		},
+		{
+			name:     "match ending at an escape",
+			patterns: []string{"err"},
+			line:     "err\x1b[1mbold",
+			want:     "«err»\x1b[1mbold",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res regexpList
+			for _, p := range tt.patterns {
+				res = append(res, regexp.MustCompile(p))
+			}
+			got := string(newHighlighter(res)("app.log", []byte(tt.line)))
+			if want := marks.Replace(tt.want); got != want {
+				t.Errorf("highlighted %q to %q, want %q", tt.line, got, want)
+			}
+		})
+	}
+}
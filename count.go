@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// countLine counts a line for --count if it would otherwise be selected for output,
+// i.e. every line, or only the lines matching --include if it is given.
+func (a *app) countLine(path string, line []byte) {
+	if len(a.include) > 0 && !a.include.matchAny(line) {
+		return
+	}
+	if a.counts == nil {
+		a.counts = make(map[string]int64)
+	}
+	a.counts[path]++
+}
+
+// emitCounts writes the tally of --count to the output: one line per file with the number of lines
+// counted since startup, sorted by count in descending order. Files that were removed are included.
+func (a *app) emitCounts(t time.Time) {
+	paths := make([]string, 0, len(a.counts))
+	for path := range a.counts {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		ci, cj := a.counts[paths[i]], a.counts[paths[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return paths[i] < paths[j]
+	})
+
+	var b strings.Builder
+	_, _ = fmt.Fprintf(&b, "\n--- counts at %s ---\n", t.Format(time.RFC3339))
+	for _, path := range paths {
+		_, _ = fmt.Fprintf(&b, "%8d %s\n", a.counts[path], a.displayPath(path))
+	}
+	a.writeOutput([]byte(b.String()))
+	a.flushOutput()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"container/list"
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// dedupEntry is a line remembered by --dedup-window, by the hash of its content.
+type dedupEntry struct {
+	key uint64
+	// output is when the line was last output.
+	output time.Time
+}
+
+// dedupFilter suppresses lines identical to one output within the last --dedup-window. Lines are
+// remembered by a 64-bit hash, in least recently seen order, and at most --dedup-max of them; the
+// least recently seen are forgotten first. It is only accessed with the output held, see pollCycle.
+type dedupFilter struct {
+	window   time.Duration
+	maxLines int
+	// acrossFiles is true if lines of different files count as duplicates of each other.
+	acrossFiles bool
+	lru         *list.List
+	entries     map[uint64]*list.Element
+	// suppressed is the number of lines suppressed since lastReport.
+	suppressed int
+	lastReport time.Time
+}
+
+// newDedupFilter creates a filter remembering at most maxLines lines for window.
+func newDedupFilter(window time.Duration, maxLines int, acrossFiles bool) *dedupFilter {
+	return &dedupFilter{
+		window:      window,
+		maxLines:    maxLines,
+		acrossFiles: acrossFiles,
+		lru:         list.New(),
+		entries:     make(map[uint64]*list.Element),
+		lastReport:  time.Now(),
+	}
+}
+
+// keep reports whether a line of a file, without its newline, is output. A duplicate within the window
+// is suppressed; once the window has passed since the line was last output, it is output again,
+// so a line repeating forever still shows up once per window.
+func (f *dedupFilter) keep(path string, line []byte, now time.Time) bool {
+	h := fnv.New64a()
+	if !f.acrossFiles {
+		_, _ = h.Write([]byte(path))
+		_, _ = h.Write([]byte{0})
+	}
+	_, _ = h.Write(line)
+	key := h.Sum64()
+
+	// Forget the least recently seen lines whose window has passed.
+	for e := f.lru.Back(); e != nil && now.Sub(e.Value.(*dedupEntry).output) >= f.window; e = f.lru.Back() {
+		f.lru.Remove(e)
+		delete(f.entries, e.Value.(*dedupEntry).key)
+	}
+
+	if e, ok := f.entries[key]; ok {
+		f.lru.MoveToFront(e)
+		entry := e.Value.(*dedupEntry)
+		if now.Sub(entry.output) < f.window {
+			f.suppressed++
+			return false
+		}
+		entry.output = now
+		return true
+	}
+	f.entries[key] = f.lru.PushFront(&dedupEntry{key: key, output: now})
+	if f.lru.Len() > f.maxLines {
+		e := f.lru.Back()
+		f.lru.Remove(e)
+		delete(f.entries, e.Value.(*dedupEntry).key)
+	}
+	return true
+}
+
+// report logs the lines suppressed since the last report, at most every limitReportInterval
+// unless force is true.
+func (f *dedupFilter) report(now time.Time, force bool) {
+	if f.suppressed == 0 || !force && now.Sub(f.lastReport) < limitReportInterval {
+		return
+	}
+	log.Printf("Info: suppressed %d duplicate lines within --dedup-window %v in the last %v\n", f.suppressed, f.window, now.Sub(f.lastReport).Round(100*time.Millisecond))
+	f.suppressed, f.lastReport = 0, now
+}
+
+// dedupKeep tells, with --dedup-window, whether a complete line, including its newline, is output.
+func (a *app) dedupKeep(path string, line []byte) bool {
+	return a.dedup == nil || a.dedup.keep(path, a.lineContent(line), time.Now())
+}
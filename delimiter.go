@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// delimiterFlag is the --record-delimiter flag: the bytes ending each record of a file, instead of
+// a newline. It is given with Go escapes, e.g. \f, \x00 or \x1e, and may be several bytes long.
+type delimiterFlag []byte
+
+// String returns the delimiter with Go escapes.
+func (d *delimiterFlag) String() string {
+	q := strconv.Quote(string(*d))
+	return q[1 : len(q)-1]
+}
+
+// Set unescapes a delimiter.
+func (d *delimiterFlag) Set(v string) error {
+	s, err := strconv.Unquote(`"` + strings.ReplaceAll(v, `"`, `\"`) + `"`)
+	if err != nil {
+		return errors.New("invalid escape sequence")
+	}
+	if s == "" {
+		return errors.New("must not be empty")
+	}
+	*d = delimiterFlag(s)
+	return nil
+}
+
+// customDelimiter reports whether records end with a --record-delimiter other than a newline.
+func (a *app) customDelimiter() bool {
+	return string(a.delim) != "\n"
+}
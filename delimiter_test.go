@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordDelimiterAcrossPolls(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "", true)
+	tt := newTestTail(t, "--record-delimiter", `\r\n\r\n`, "--include", "rec", filepath.Join(dir, "*.log"))
+
+	steps := []struct {
+		write, want string
+	}{
+		// The delimiter straddles the polls, so the record is only complete in the second one.
+		{"rec 1\nstill rec 1\r\n", ""},
+		{"\r\nrec 2\r", "rec 1\nstill rec 1\r\n\r\n"},
+		{"\n\r", ""},
+		{"\nno match\r\n\r\nrec 3\r\n\r\n", "rec 2\r\n\r\nrec 3\r\n\r\n"},
+	}
+	for i, s := range steps {
+		writeFile(t, path, s.write, false)
+		if got := tt.poll(); got != s.want {
+			t.Errorf("poll %d: output %q, want %q", i, got, s.want)
+		}
+	}
+}
+
+func TestDelimiterFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		err   bool
+	}{
+		{`\x1e`, "\x1e", false},
+		{`\r\n`, "\r\n", false},
+		{`--END--`, "--END--", false},
+		{`"`, `"`, false},
+		{``, "", true},
+		{`\q`, "", true},
+	}
+	for _, tt := range tests {
+		var d delimiterFlag
+		err := d.Set(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("Set(%q): error %v, want one: %v", tt.value, err, tt.err)
+			continue
+		}
+		if err == nil && string(d) != tt.want {
+			t.Errorf("Set(%q) = %q, want %q", tt.value, d, tt.want)
+		}
+	}
+}
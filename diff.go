@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"slices"
+)
+
+// defaultDiffMaxSize is the default --diff-max-size.
+const defaultDiffMaxSize = 1 << 20
+
+// diffState is the state of a file followed with --diff-mode.
+type diffState struct {
+	// content is what was read of the current version of the file, or nil if it grew past
+	// --diff-max-size, which overflow tells.
+	content  []byte
+	overflow bool
+	// old counts the lines of the previous version not seen in the current one yet,
+	// or is nil if the file was not replaced since it was added.
+	old map[string]int
+}
+
+// isDiffMode reports whether the files of a pattern are followed as rewritten files with --diff-mode.
+func (a *app) isDiffMode(state *fileState) bool {
+	return len(a.diffMode) > 0 && slices.Contains(a.diffMode, state.pattern)
+}
+
+// keep adds data read from the current version to its content, unless it gets too large.
+func (d *diffState) keep(data []byte, maxSize int64) {
+	if d.overflow {
+		return
+	}
+	if int64(len(d.content)+len(data)) > maxSize {
+		d.content, d.overflow = nil, true
+		return
+	}
+	d.content = append(d.content, data...)
+}
+
+// initDiff reads the content of a --diff-mode file up to offset when it is added, as the version
+// later ones are compared with. Files larger than --diff-max-size are output in full when replaced.
+func (a *app) initDiff(path string, state *fileState) {
+	if !a.isDiffMode(state) || state.offset == 0 {
+		return
+	}
+	if state.offset > int64(a.diffMaxSize) {
+		state.diff.overflow = true
+		return
+	}
+	file, err := a.openFile(path)
+	if err != nil {
+		state.diff.overflow = true
+		return
+	}
+	defer a.closeFile(file)
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, state.offset))
+	if err != nil {
+		state.diff.overflow = true
+		return
+	}
+	state.diff.content = data
+}
+
+// diffReplaced makes the previous version of a --diff-mode file the one the replacing version
+// is compared with.
+func (a *app) diffReplaced(path string, state *fileState) {
+	if !a.isDiffMode(state) {
+		return
+	}
+	d := &state.diff
+	if d.overflow {
+		log.Printf("Info: The previous version of %s was larger than --diff-max-size, outputting the new one in full.\n", path)
+		d.old = nil
+	} else {
+		d.old = make(map[string]int)
+		for _, line := range splitRecords(d.content, a.delim) {
+			d.old[string(bytes.TrimSuffix(line, a.delim))]++
+		}
+	}
+	d.content, d.overflow = nil, false
+}
+
+// diffData returns the lines of data, read from a --diff-mode file, that are not in the version
+// it replaced, counting repeated lines; each line of the previous version matches one line
+// of the new one. Data of a file not replaced since it was added is returned as is.
+func (a *app) diffData(state *fileState, data []byte) []byte {
+	if !a.isDiffMode(state) {
+		return data
+	}
+	d := &state.diff
+	d.keep(data, int64(a.diffMaxSize))
+	if d.old == nil {
+		return data
+	}
+	var added []byte
+	for _, line := range splitRecords(data, a.delim) {
+		// A last line without a delimiter matches the same line with one.
+		key := string(bytes.TrimSuffix(line, a.delim))
+		if d.old[key] > 0 {
+			d.old[key]--
+			continue
+		}
+		added = append(added, line...)
+	}
+	return added
+}
+
+// splitRecords splits data after each delimiter. A last record without one is returned as well.
+func splitRecords(data, delim []byte) [][]byte {
+	var records [][]byte
+	for len(data) > 0 {
+		end := len(data)
+		if i := bytes.Index(data, delim); i >= 0 {
+			end = i + len(delim)
+		}
+		records = append(records, data[:end])
+		data = data[end:]
+	}
+	return records
+}
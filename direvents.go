@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of --watch-dir-events records.
+const (
+	dirEventCreated = "created"
+	dirEventRemoved = "removed"
+)
+
+// dirEvent is a change of the files in the watched directories, output with --watch-dir-events.
+type dirEvent struct {
+	Seq   uint64    `json:"seq,omitempty"`
+	Event string    `json:"event"`
+	File  string    `json:"file"`
+	Time  time.Time `json:"time"`
+}
+
+// dirEvents queues the --watch-dir-events records. Files are added and removed by the directory
+// watcher and the scan, so the records are written to the output by the polling goroutine,
+// see writeDirEvents.
+type dirEvents struct {
+	mu      sync.Mutex
+	pending []dirEvent
+}
+
+// add queues a record.
+func (d *dirEvents) add(ev dirEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = append(d.pending, ev)
+}
+
+// take returns the records queued since the last call.
+func (d *dirEvents) take() []dirEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pending := d.pending
+	d.pending = nil
+	return pending
+}
+
+// noteDirEvent queues a --watch-dir-events record for a file that started or stopped being watched.
+func (a *app) noteDirEvent(event, path string) {
+	if a.dirEvents != nil {
+		a.dirEvents.add(dirEvent{Event: event, File: a.displayPath(path), Time: time.Now()})
+	}
+}
+
+// writeDirEvents writes the --watch-dir-events records queued since the last call, in the --format:
+//   - json: an object {"event":"created","file":"...","time":"..."}, or with "event":"removed".
+//   - logfmt: time=... event=created file=...
+//   - raw and text: a line "TIME created PATH", with the time in RFC 3339.
+//
+// It is called by the polling goroutine every poll cycle and before exiting.
+func (a *app) writeDirEvents() {
+	if a.dirEvents == nil {
+		return
+	}
+	for _, ev := range a.dirEvents.take() {
+		ev.Seq = a.nextSeq()
+		var b []byte
+		switch a.format {
+		case "json":
+			b, _ = json.Marshal(ev)
+			b = append(b, '\n')
+		case "logfmt":
+			var s bytes.Buffer
+			if ev.Seq > 0 {
+				s.WriteString("seq=" + strconv.FormatUint(ev.Seq, 10) + " ")
+			}
+			s.WriteString("time=" + ev.Time.Format(time.RFC3339Nano) + " event=" + ev.Event + " file=")
+			writeLogfmtValue(&s, ev.File)
+			s.WriteByte('\n')
+			b = s.Bytes()
+		default:
+			line := ev.Time.Format(time.RFC3339Nano) + " " + ev.Event + " " + ev.File + "\n"
+			if ev.Seq > 0 {
+				line = strconv.FormatUint(ev.Seq, 10) + " " + line
+			}
+			b = []byte(line)
+		}
+		if a.print0 {
+			b[len(b)-1] = 0
+		}
+		a.writeOutput(b)
+	}
+}
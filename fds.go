@@ -0,0 +1,23 @@
+package main
+
+// openFile opens a watched file for reading from the fileSource. With --max-open-fds, it waits while that many
+// files are open already, so large watch sets or many --read-workers cannot exhaust the
+// file descriptors. Files opened by it must be closed with closeFile.
+func (a *app) openFile(path string) (sourceFile, error) {
+	if a.fds != nil {
+		a.fds <- struct{}{}
+	}
+	file, err := a.source.Open(path)
+	if err != nil && a.fds != nil {
+		<-a.fds
+	}
+	return file, err
+}
+
+// closeFile closes a file opened by openFile.
+func (a *app) closeFile(file sourceFile) {
+	_ = file.Close()
+	if a.fds != nil {
+		<-a.fds
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package main
+
+import "os"
+
+// deviceOf is not supported on this platform.
+func deviceOf(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileID is not supported on this platform.
+func fileID(_ string, _ os.FileInfo) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,39 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// deviceOf is not supported on Windows, as os.FileInfo carries no volume information there.
+func deviceOf(_ os.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// fileID returns a comparable identity of a file, made of its volume serial number and file index.
+// It stays the same when the file is renamed, and changes when another file replaces it.
+// os.FileInfo carries neither on Windows, so the file is opened by path to query them.
+func fileID(path string, _ os.FileInfo) (string, bool) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return "", false
+	}
+	// Share everything, so the writer of the file is not disturbed.
+	h, err := syscall.CreateFile(p, 0,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return "", false
+	}
+	defer func() { _ = syscall.CloseHandle(h) }()
+
+	var info syscall.ByHandleFileInformation
+	if err = syscall.GetFileInformationByHandle(h, &info); err != nil {
+		return "", false
+	}
+	index := uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+	return strconv.FormatUint(uint64(info.VolumeSerialNumber), 10) + ":" + strconv.FormatUint(index, 10), true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Orders of --first-match-by.
+const (
+	firstMatchNewest = "newest"
+	firstMatchName   = "name"
+)
+
+// validFirstMatchBy checks a --first-match-by.
+func validFirstMatchBy(by string) error {
+	if by != firstMatchNewest && by != firstMatchName {
+		return fmt.Errorf("--first-match-by must be %s or %s, got %q", firstMatchNewest, firstMatchName, by)
+	}
+	return nil
+}
+
+// firstMatches collects the files each pattern matches during a scan with --first-match,
+// so only the first of each is watched.
+type firstMatches struct {
+	// source is where the modification times of the files are read from.
+	source   fileSource
+	patterns []string
+	files    map[string][]firstMatchCandidate
+}
+
+// firstMatchCandidate is a file matched by a pattern, with its modification time.
+type firstMatchCandidate struct {
+	path    string
+	modTime time.Time
+}
+
+// add collects a file matched by a pattern.
+func (m *firstMatches) add(realPath, pattern string) {
+	if m.files == nil {
+		m.files = make(map[string][]firstMatchCandidate)
+	}
+	if _, ok := m.files[pattern]; !ok {
+		m.patterns = append(m.patterns, pattern)
+	}
+	c := firstMatchCandidate{path: realPath}
+	if fi, err := m.source.Stat(realPath); err == nil {
+		c.modTime = fi.ModTime()
+	}
+	m.files[pattern] = append(m.files[pattern], c)
+}
+
+// first returns the file of a pattern to watch, by --first-match-by: the most recently modified,
+// or the first by name. Ties are broken by name.
+func (m *firstMatches) first(pattern, by string) string {
+	var best firstMatchCandidate
+	for i, c := range m.files[pattern] {
+		switch {
+		case i == 0:
+		case by == firstMatchNewest && c.modTime.After(best.modTime):
+		case by == firstMatchNewest && c.modTime.Equal(best.modTime) && c.path < best.path:
+		case by == firstMatchName && c.path < best.path:
+		default:
+			continue
+		}
+		best = c
+	}
+	return best.path
+}
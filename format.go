@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// record is a single line of a file, as passed to a formatter.
+type record struct {
+	// File is the real path of the file.
+	File string
+	// Time is when the line was output.
+	Time time.Time
+	// Line is the content of the line, without its trailing newline.
+	Line []byte
+	// Seq is the --seqno sequence number of the record, or 0 without --seqno.
+	Seq uint64
+	// FileID is the identity of the file with --emit-file-id, or empty.
+	FileID string
+	// Offset and Length are where the line is in the file with --emit-offsets, if HasOffset is true.
+	Offset    int64
+	Length    int
+	HasOffset bool
+	// LogTime is the timestamp of the line with --auto-timestamp, or zero if it has none.
+	LogTime time.Time
+	// RealPath, LineNo, and Tag are the real path of the file, the number of the line, and the glob
+	// pattern that matched the file. They are only set for --output-template, see templateFormatter.
+	RealPath string
+	LineNo   int
+	Tag      string
+}
+
+// formatter serializes records for --format. Formats are added by implementing it
+// and registering it in formatters; the read loop does not need to change. Like record,
+// it is internal to the command, with no importable API.
+type formatter interface {
+	// Format returns the bytes to output for a record, including any trailing newline.
+	Format(rec record) ([]byte, error)
+}
+
+// formatRaw is the default --format. It writes the content of files as read, under headers naming them,
+// and needs no formatter.
+const formatRaw = "raw"
+
+// formatters are the formatters selectable with --format, by name.
+var formatters = map[string]formatter{
+	"json":   jsonFormatter{},
+	"text":   textFormatter{},
+	"logfmt": logfmtFormatter{},
+}
+
+// newFormatter returns the formatter for a --format name, or nil for raw output.
+func newFormatter(name string) (formatter, error) {
+	if name == formatRaw {
+		return nil, nil
+	}
+	if f, ok := formatters[name]; ok {
+		return f, nil
+	}
+	names := []string{formatRaw}
+	for n := range formatters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("--format must be one of %s, got %q", strings.Join(names, ", "), name)
+}
+
+// jsonFormatter writes a JSON object per line, with the same fields as the --serve events.
+type jsonFormatter struct{}
+
+// Format implements formatter.
+func (jsonFormatter) Format(rec record) ([]byte, error) {
+	ev := streamEvent{Seq: rec.Seq, File: rec.File, FileID: rec.FileID, Time: rec.Time, Line: string(rec.Line)}
+	if rec.HasOffset {
+		ev.Offset, ev.Length = &rec.Offset, rec.Length
+	}
+	if !rec.LogTime.IsZero() {
+		ev.LogTime = &rec.LogTime
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// textFormatter writes each line prefixed with the path of its file, like grep does for several files.
+// With --seqno, the sequence number comes first, and with --emit-file-id, the identity of the file
+// follows its path in parentheses.
+type textFormatter struct{}
+
+// Format implements formatter.
+func (textFormatter) Format(rec record) ([]byte, error) {
+	b := make([]byte, 0, 21+len(rec.File)+2+len(rec.Line)+1)
+	if rec.Seq > 0 {
+		b = strconv.AppendUint(b, rec.Seq, 10)
+		b = append(b, ' ')
+	}
+	b = append(b, rec.File...)
+	if rec.FileID != "" {
+		b = append(b, " ("+rec.FileID+")"...)
+	}
+	b = append(b, ": "...)
+	b = append(b, rec.Line...)
+	return append(b, '\n'), nil
+}
+
+// logfmtFormatter writes a logfmt line per line, e.g. time=2006-01-02T15:04:05Z file=/var/log/a.log line="GET /".
+type logfmtFormatter struct{}
+
+// Format implements formatter.
+func (logfmtFormatter) Format(rec record) ([]byte, error) {
+	var b bytes.Buffer
+	if rec.Seq > 0 {
+		b.WriteString("seq=")
+		b.WriteString(strconv.FormatUint(rec.Seq, 10))
+		b.WriteByte(' ')
+	}
+	b.WriteString("time=")
+	b.WriteString(rec.Time.Format(time.RFC3339Nano))
+	b.WriteString(" file=")
+	writeLogfmtValue(&b, rec.File)
+	if rec.FileID != "" {
+		b.WriteString(" file_id=")
+		b.WriteString(rec.FileID)
+	}
+	if rec.HasOffset {
+		b.WriteString(" offset=")
+		b.WriteString(strconv.FormatInt(rec.Offset, 10))
+		b.WriteString(" length=")
+		b.WriteString(strconv.Itoa(rec.Length))
+	}
+	if !rec.LogTime.IsZero() {
+		b.WriteString(" log_time=")
+		b.WriteString(rec.LogTime.Format(time.RFC3339Nano))
+	}
+	b.WriteString(" line=")
+	writeLogfmtValue(&b, string(rec.Line))
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// writeLogfmtValue writes a logfmt value, quoted and escaped if it is empty or contains spaces,
+// equal signs, quotes, or non-printable characters.
+func writeLogfmtValue(b *bytes.Buffer, v string) {
+	needsQuotes := v == ""
+	for _, r := range v {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !strconv.IsPrint(r) {
+			needsQuotes = true
+			break
+		}
+	}
+	if !needsQuotes {
+		b.WriteString(v)
+		return
+	}
+	b.WriteString(strconv.Quote(v))
+}
+
+// writeFormatted writes each line in data, which holds complete lines of a file, through the formatter.
+// Lines the formatter fails on are logged and skipped.
+func (a *app) writeFormatted(path, id string, data []byte) {
+	now := time.Now()
+	var out bytes.Buffer
+	for len(data) > 0 {
+		line := data
+		if i := bytes.Index(data, a.delim); i >= 0 {
+			line, data = data[:i], data[i+len(a.delim):]
+		} else {
+			data = nil
+		}
+		rec := record{File: path, Time: now, Line: line, Seq: a.nextSeq(), FileID: id}
+		if span, ok := a.nextSpan(); ok {
+			if a.emitOffsets && span.at >= 0 {
+				rec.Offset, rec.Length, rec.HasOffset = span.at, span.n, true
+			}
+			rec.LogTime = span.stamp
+		}
+		b, err := a.formatter.Format(rec)
+		if err != nil {
+			a.logError("formatting a line of %s: %v\n", path, err)
+			continue
+		}
+		if a.print0 {
+			b = append(bytes.TrimSuffix(b, []byte("\n")), 0)
+		}
+		out.Write(b)
+	}
+	a.writeOutput(out.Bytes())
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// Framing modes for --framing.
+const (
+	// framingNone writes lines as they are, under a header naming the file.
+	framingNone = "none"
+	// framingLength writes each line as a length-prefixed frame, see writeFrames.
+	framingLength = "length"
+)
+
+// validFraming reports an error if mode is not a known --framing mode.
+func validFraming(mode string) error {
+	switch mode {
+	case framingNone, framingLength:
+		return nil
+	default:
+		return fmt.Errorf("--framing must be %q or %q, got %q", framingNone, framingLength, mode)
+	}
+}
+
+// writeFrames writes each line in data, which holds complete lines of a file, as a frame
+// for programs reading the output:
+//
+//	length  4 bytes, big-endian: the number of bytes of the payload
+//	payload the path of the file, a NUL byte, and the line without its trailing newline
+//
+// No delimiter needs to be parsed, so lines may contain any bytes. Paths cannot contain NUL.
+// Frames are flushed like lines, see writeOutput.
+func (a *app) writeFrames(path string, data []byte) {
+	var header [4]byte
+	for len(data) > 0 {
+		line := data
+		if i := bytes.Index(data, a.delim); i >= 0 {
+			line, data = data[:i], data[i+len(a.delim):]
+		} else {
+			data = nil
+		}
+
+		if a.seqno {
+			line = append(strconv.AppendUint(nil, a.nextSeq(), 10), append([]byte{' '}, line...)...)
+		}
+		binary.BigEndian.PutUint32(header[:], uint32(len(path)+1+len(line)))
+		_, _ = a.out.Write(header[:])
+		_, _ = a.out.WriteString(path)
+		_ = a.out.WriteByte(0)
+		_, _ = a.out.Write(line)
+		if a.lineBuffered {
+			_ = a.out.Flush()
+		}
+	}
+	if !a.buffered && !a.lineBuffered {
+		_ = a.out.Flush()
+	}
+}
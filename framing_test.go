@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// frame is a decoded --framing length frame.
+type frame struct {
+	path, line string
+}
+
+// decodeFrames is a reference decoder of --framing length output, as a consumer would write it.
+// It fails on a truncated frame or a payload without the NUL ending the path.
+func decodeFrames(r io.Reader) ([]frame, error) {
+	var frames []frame
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return frames, fmt.Errorf("reading frame header: %w", err)
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return frames, fmt.Errorf("reading frame payload: %w", err)
+		}
+		path, line, ok := bytes.Cut(payload, []byte{0})
+		if !ok {
+			return frames, errors.New("frame payload without a path")
+		}
+		frames = append(frames, frame{string(path), string(line)})
+	}
+}
+
+func TestFraming(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	writeFile(t, a, "", true)
+	writeFile(t, b, "", true)
+	tt := newTestTail(t, "--framing", "length", filepath.Join(dir, "*.log"))
+
+	// Lines may hold any bytes but the newline, including NUL and bytes that look like a header.
+	writeFile(t, a, "one\nwith\x00nul\n\x00\x00\x00\x05\n", false)
+	writeFile(t, b, "\nlast without newline", false)
+	tt.cycle()
+	tt.a.flushPartialLines()
+	tt.a.finishOutput()
+
+	frames, err := decodeFrames(&tt.out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The files are read in no particular order, so their frames are compared per file.
+	got := make(map[string][]string)
+	for _, f := range frames {
+		got[f.path] = append(got[f.path], f.line)
+	}
+	want := map[string][]string{
+		a: {"one", "with\x00nul", "\x00\x00\x00\x05"},
+		b: {"", "last without newline"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("frames %q, want %q", got, want)
+	}
+}
+
+func TestDecodeFramesTruncated(t *testing.T) {
+	if _, err := decodeFrames(bytes.NewReader([]byte{0, 0, 0, 9, 'a', 0, 'b'})); err == nil {
+		t.Error("no error for a truncated frame")
+	}
+}
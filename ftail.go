@@ -7,10 +7,16 @@
 // Features:
 // - Supports glob patterns (e.g., `/var/log/**/*.log`) to specify multiple files.
 // - Resolves symbolic links to avoid watching the same file twice.
-// - Files added to the watch list initially are read from the end.
+// - Files added to the watch list initially are read from the end, or from the beginning with `--from-start`.
+// - With `--stop-at-eof`, it exits once every file has been read to the end, like a multi-file `cat`.
 // - New files created that match the glob patterns are automatically added to the watch list.
 // - Files that are deleted or renamed are automatically removed from the watch list.
 // - The tool efficiently manages file descriptor resources by opening and closing files for each read operation.
+// - Flags and glob patterns can be read from a config file, which is reloaded on SIGHUP.
+// - With `--serve`, the tailed lines are streamed to browsers as Server-Sent Events.
+// - With `--include`, only matching lines are output, optionally with surrounding context lines per file.
+// - With `--start-after`, output of each file starts after a line matching a marker.
+// - With `--archive`, the members of a tar or tar.gz archive are read as files, for post-mortem analysis.
 //
 // Build Instructions:
 // A Go compiler is required to build this program. Run the following commands to
@@ -41,14 +47,26 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -60,110 +78,1244 @@ type args struct {
 	pollInterval time.Duration
 	scanInterval time.Duration
 	dispInterval time.Duration
-}
+	configPath   string
+	debugOffsets bool
+	fromStart    bool
+	inclusive    bool
+	stopAtEOF    bool
+	eofGrace     time.Duration
+	// patterns holds the glob patterns given as positional arguments and in the config file.
+	patterns           []string
+	serveAddr          string
+	serveBuffer        int
+	sparseProbe        bool
+	include            regexpList
+	beforeCtx          int
+	afterCtx           int
+	pollOnly           bool
+	list               bool
+	buffered           bool
+	lineBuffered       bool
+	stripANSI          bool
+	redact             bool
+	redactEmails       bool
+	redactPatterns     regexpList
+	count              bool
+	retryInterval      time.Duration
+	startAfter         regexpList
+	startAfterReset    bool
+	maxDirWatches      int
+	framing            string
+	stripBOM           bool
+	quietFiles         int
+	groupByFile        bool
+	groupWindow        time.Duration
+	adaptiveMax        time.Duration
+	readDevices        bool
+	checksumVerify     bool
+	strict             bool
+	format             string
+	maxBytesPerSec     int64
+	onLimit            string
+	regex              bool
+	root               string
+	print0             bool
+	offsetFrom         string
+	readWorkers        int
+	maxOpenFDs         int
+	hashPaths          bool
+	manifest           string
+	output             string
+	outputRotateSize   byteSize
+	outputRotateTime   time.Duration
+	outputRotateGzip   bool
+	watchSummary       int
+	archive            string
+	teeLogs            bool
+	outputTimeout      time.Duration
+	onOutputError      string
+	textOnly           bool
+	sniffBytes         int
+	seqno              bool
+	batchMarkers       string
+	batchEmpty         bool
+	outputTemplate     string
+	literal            bool
+	maxFileSize        byteSize
+	minFileSize        byteSize
+	sample             sampleFlag
+	replayLines        int
+	jsonArray          patternList
+	printOffsets       bool
+	color              string
+	mergeBy            string
+	mergeRegex         string
+	minLevel           string
+	levelRegex         string
+	levelOrder         string
+	keepUnleveled      bool
+	pauseMode          string
+	delim              delimiterFlag
+	emitFileID         bool
+	checkpoint         timeFlag
+	onMatch            onMatchList
+	onMatchWorkers     int
+	onMatchRate        int64
+	onMatchTimeout     time.Duration
+	dedupWindow        time.Duration
+	dedupMax           int
+	dedupAcrossFiles   bool
+	markLive           bool
+	listenUnix         string
+	listenUnixMode     fileModeFlag
+	emitOffsets        bool
+	ignoreFile         string
+	statusLine         bool
+	progress           bool
+	progressInterval   time.Duration
+	diffMode           patternList
+	diffMaxSize        byteSize
+	integrityFooter    bool
+	integrityHash      string
+	pathStyle          string
+	maxLineRate        int
+	rateSampleRatio    int
+	watchDirEvents     bool
+	minFileAge         time.Duration
+	autoTimestamp      bool
+	watchOps           string
+	logFormat          string
+	tailBytes          byteSize
+	traceEvents        bool
+	maxBufferMemory    byteSize
+	bufferPolicy       string
+	firstMatch         bool
+	firstMatchBy       string
+	reverse            bool
+	squeezeBlank       bool
+	splitOutput        string
+	splitOutputMaxOpen int
+	warnOnGap          byteSize
+	onGap              string
+	recursive          bool
+	sshCommand         string
+	stateFile          string
+	stateInterval      time.Duration
+	stateTTL           time.Duration
+	// remote is the host of sftp:// patterns, taken out of them by validate, or the zero value
+	// for local patterns.
+	remote sftpTarget
 
-// theArgs is a global variable to hold parsed command-line flags.
-var theArgs args
+	// cmdName and cmdArgs are the command line the args were parsed from, re-parsed on reload,
+	// and flagValues the resulting value of each flag, to tell which ones a reload changes.
+	cmdName    string
+	cmdArgs    []string
+	flagValues map[string]string
+}
 
 // app holds the main state of the ftail application.
 type app struct {
 	// watchedFiles is a map of files being watched.
-	// The key is the file's real path and the value is the read offset.
+	// The key is the file's real path and the value is its *fileState.
 	// We use sync.Map for thread-safe access from multiple goroutines.
 	watchedFiles sync.Map
 	// The key is the dir's real path and the value is the result of error of dirWatcher.Add.
 	// We use sync.Map for thread-safe access from multiple goroutines.
 	watchedDirs sync.Map
-	// globPatterns is a list of glob patterns provided via command line.
-	globPatterns []string
+	// globPatterns is a list of glob patterns provided via command line and config file,
+	// precompiled by setPatterns. It is replaced on SIGHUP, so access it through patterns()
+	// while goroutines are running.
+	globPatterns []globPattern
+	// patternsByBase maps the absolute and real base directories of the glob patterns to their
+	// indexes in globPatterns, so a path is only matched against patterns whose base contains it.
+	patternsByBase map[string][]int
+	// manifestEntries are the literal patterns of the paths listed in the --manifest file,
+	// as last read. Only the scanning goroutine accesses it once tailing has started.
+	manifestEntries []string
+	// givenPatterns are the patterns last passed to setPatterns, and patternDirs tells which of
+	// them named a directory then, see directoryPattern. Only the scanning goroutine accesses them
+	// once tailing has started.
+	givenPatterns []string
+	patternDirs   map[string]bool
+	// startupWatches collects the files and directories watched by the first setupWatchers,
+	// to log them as a summary with --watch-summary. It is nil afterwards.
+	startupWatches *startupWatches
+	// inBatch is true during a poll cycle with --batch-markers, batchOpen once its begin marker
+	// is written, and batchNo the number of the last batch. Only the polling goroutine uses them.
+	inBatch   bool
+	batchOpen bool
+	batchNo   uint64
+	// lineTemplate renders the lines with --output-template. It is nil otherwise, leaving the default path as is.
+	lineTemplate *templateFormatter
+	// seq is the last --seqno sequence number taken, see nextSeq.
+	seq atomic.Uint64
+	// belowMinSize holds the real paths of files skipped for being smaller than --min-file-size.
+	// Once they reach it, they are read from the start, as all their content is new.
+	belowMinSize sync.Map
+	// youngFiles maps the real paths of files skipped for being younger than --min-file-age
+	// to when they were first found. agedFiles holds those that aged in, until they are added,
+	// as they are read from the start.
+	youngFiles sync.Map
+	agedFiles  sync.Map
+	// spans are the --emit-offsets and --auto-timestamp spans of the lines being emitted, see addSpan.
+	spans []lineSpan
+	// ignores holds the --ignore-file rules read, or is nil.
+	ignores *ignoreCache
+	// status is the --status-line, or nil if it is not shown.
+	status *statusLine
+	// dirEvents queues the --watch-dir-events records, or is nil.
+	dirEvents *dirEvents
+	// integrity tallies the content of each file for --integrity-footer, or is nil.
+	integrity *integrityFooters
+	// progressLog logs the progress of --stop-at-eof with --progress, or is nil.
+	progressLog *progressTracker
+	// dirDevices holds the device of the directory of each watched file, and lastRemountCheck when
+	// they were last checked, see checkRemounts. remountInterval is the --scan-interval they are
+	// checked at, a copy owned by the polling goroutine, as the scanning goroutine owns scanInterval.
+	dirDevices       sync.Map
+	lastRemountCheck time.Time
+	remountInterval  time.Duration
+	// state holds the --state-file entries, or is nil, and lastStateSave is when it was last saved.
+	// Only the polling goroutine saves it.
+	state         *stateStore
+	lastStateSave time.Time
+	// split writes the content of each file to its own --split-output file, or is nil.
+	split *splitOutputs
+	// bufferMemory keeps the buffers within --max-buffer-memory, or is nil.
+	bufferMemory *bufferAccountant
+	// watchOpMask is the directory watcher operations acted on, see --watch-ops.
+	watchOpMask fsnotify.Op
+	// dedup suppresses duplicate lines with --dedup-window, or is nil.
+	dedup *dedupFilter
+	// matches runs the --on-match commands, or is nil.
+	matches *matchRunner
+	// levels selects the lines to output by severity with --min-level, or is nil.
+	levels *levelFilter
+	// mergeRe is the compiled --merge-regex.
+	mergeRe *regexp.Regexp
+	// source is where the watched files are read from, see fileSource, and discovery how they are found.
+	source    fileSource
+	discovery discovery
+	// paused is set while following is paused by a signal, see handlePauseSignals,
+	// and pauseDropped counts the bytes dropped meanwhile with --pause-mode drop.
+	paused       atomic.Bool
+	pauseDropped atomic.Int64
+	// replay asks the polling goroutine for a replay of the last lines, see replayFiles.
+	replay chan struct{}
+	// logTee queues the log messages to write to the output with --tee-stderr-errors-to-output.
+	// It is nil otherwise.
+	logTee *logTee
+	// patternsMu guards globPatterns and patternsByBase against concurrent reloads.
+	patternsMu sync.RWMutex
+	// pollReload and scanReload deliver reloaded arguments to the goroutines that own the tickers.
+	pollReload chan *args
+	scanReload chan *args
+	// workDir is the working directory ftail was started in, for --path-style relative.
+	workDir string
+	// startTime is when ftail started. Used by --inclusive-initial to tell new files from old ones.
+	startTime time.Time
+	// done is closed by pollFiles when the application should exit, e.g. when --stop-at-eof
+	// is satisfied or a signal was received, after the final output has been written.
+	done chan struct{}
+	// prevPath is the path of the file whose content was emitted last.
+	// It is only accessed from the polling goroutine.
+	prevPath string
+	// counts holds the number of lines counted per file for --count.
+	// It is only accessed from the polling goroutine.
+	counts map[string]int64
+	// transforms are applied to every line selected for output. See addLineTransform.
+	transforms []lineTransform
+	// out is the buffered writer for standard output. It is only accessed from the polling goroutine.
+	out *bufio.Writer
+	// formatter serializes the output lines for --format. It is nil for raw output.
+	formatter formatter
+	// limiter caps the combined output rate for --max-bytes-per-sec. It is nil without a limit,
+	// and only accessed from the polling goroutine.
+	limiter *throughputLimiter
+	// fds holds a token for each file opened by openFile, with --max-open-fds. It is nil without a limit.
+	fds chan struct{}
+	// groups collects content per file for --group-by-file. It is only accessed from the polling goroutine.
+	groups fileGroups
+	// streams fans emitted content out to the HTTP clients of --serve. It is nil if --serve is not given.
+	streams *broadcaster
+	// pathHashes records the paths whose hash has been logged, for --hash-paths.
+	pathHashes sync.Map
+	// reportedNotes records the walk decisions already logged, so each is logged once.
+	reportedNotes sync.Map
+	// newIdleTicker creates the ticker of the no-change message, see idleTicker.
+	newIdleTicker func(d time.Duration) (ticks <-chan time.Time, stop func())
+	// symlinkRetries holds the paths of the symlinks whose resolving is retried, see evalSymlinks:
+	// true while retrying, false once the retries ran out. symlinkRescan asks the scan goroutine for
+	// a scan when they end.
+	symlinkRetries sync.Map
+	symlinkRescan  chan struct{}
+	// sparseFallback logs only once that --sparse-probe is unavailable.
+	sparseFallback sync.Once
+	// dirWatches is the number of directories successfully added to dirWatcher, for --max-dir-watches.
+	dirWatches atomic.Int64
+	// dirWatchLimit logs only once that --max-dir-watches was reached.
+	dirWatchLimit sync.Once
+	// cancel stops ftail, and fatalErr is the error it stopped on with --strict, set once through fatalOnce.
+	cancel    context.CancelFunc
+	fatalOnce sync.Once
+	fatalErr  error
 	// dirWatcher is a watcher for directory changes.
 	// It uses fsnotify to detect file creation, deletion, and renaming.
+	// It is nil with --poll-only.
 	dirWatcher *fsnotify.Watcher
 	// args is an anonymous field that allows direct access to the command-line arguments.
 	*args
 }
 
-// init is executed before the main function to parse flags.
-func init() {
-	// Parse flags and set the values directly on the _args struct.
-	flag.DurationVar(&theArgs.pollInterval, "poll-interval", 500*time.Millisecond, "Interval to poll files for new content")
-	flag.DurationVar(&theArgs.scanInterval, "scan-interval", 3*time.Second, "Interval to scan for new files matching glob patterns")
-	flag.DurationVar(&theArgs.dispInterval, "disp-interval", 1*time.Minute, "Interval for showing no files changed")
+// fileState is the state of a single watched file.
+// It is created by addToWatchFile and afterward only accessed from the polling goroutine.
+type fileState struct {
+	// offset is the position up to which the file has been read.
+	offset int64
+	// pattern is the glob pattern that matched the file, as given by the user.
+	pattern string
+	// id is the identity of the file as returned by fileID, or "" if it is unknown.
+	// A change means the file was replaced by another one under the same path.
+	id string
+	// lines is the state of line-oriented processing, used when lineMode is on.
+	lines lineState
+	// lastActivity is when new content was last read from the file, or when it was added.
+	lastActivity time.Time
+	// pollEvery is the current poll interval of the file with --poll-interval adaptive,
+	// and nextPoll when it is due next. See schedulePoll.
+	pollEvery time.Duration
+	nextPoll  time.Time
+	// headerLen and headerSum are the length and CRC-32 of the start of the file, for --checksum-verify.
+	headerLen int
+	headerSum uint32
+	// unreadable is true while the file cannot be opened, e.g. for lack of permission, so the error is
+	// logged once rather than every poll. It is also set by the directory watcher on Chmod events.
+	unreadable atomic.Bool
+	// backlogEnd is the size of the file when it was added, if it was read from before the end,
+	// until the backlog has been read with --mark-live. It is 0 otherwise.
+	backlogEnd int64
+	// diff is the state of --diff-mode, and rate the line rate of --max-line-rate-per-file.
+	diff diffState
+	rate lineRate
+	// reversed is true once --reverse has output the file backward.
+	reversed bool
+	// gapEnd is the end of the last growth --warn-on-gap warned about.
+	gapEnd int64
+}
+
+// globPattern is a glob pattern split into its base directory and the rest of the pattern.
+// The split is done once, so matching a single path does not need to walk the filesystem.
+type globPattern struct {
+	// raw is the pattern as given by the user.
+	raw string
+	// base is the static directory part of the pattern, and pattern is the remainder
+	// relative to it, as returned by doublestar.SplitPattern.
+	base    string
+	pattern string
+	// absBase is the absolute form of base, and realBase is absBase with symlinks resolved.
+	// realBase equals absBase if the base directory could not be resolved.
+	absBase  string
+	realBase string
+	// re is the regular expression of a --regex pattern, matched against absolute paths below base
+	// instead of pattern. It is nil for glob patterns.
+	re *regexp.Regexp
+}
+
+// compilePattern splits a glob pattern and resolves its base directory with d.
+func compilePattern(raw string, d discovery) globPattern {
+	base, pattern := doublestar.SplitPattern(raw)
+	absBase, realBase := resolveBase(base, d)
+	return globPattern{raw: raw, base: base, pattern: pattern, absBase: absBase, realBase: realBase}
+}
+
+// resolveBase returns the absolute form of a base directory, and that with symlinks resolved.
+func resolveBase(base string, d discovery) (absBase, realBase string) {
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		absBase = base
+	}
+	realBase, err = d.EvalSymlinks(absBase)
+	if err != nil {
+		realBase = absBase
+	}
+	return absBase, realBase
+}
+
+// match reports whether path, an absolute path, matches the pattern
+// either below the base directory as given or below its resolved real path.
+func (g globPattern) match(path string) bool {
+	for _, base := range []string{g.absBase, g.realBase} {
+		rel, err := filepath.Rel(base, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if g.re != nil {
+			if g.re.MatchString(path) {
+				return true
+			}
+		} else if ok, _ := doublestar.Match(g.pattern, filepath.ToSlash(rel)); ok {
+			return true
+		}
+		if g.absBase == g.realBase {
+			break
+		}
+	}
+	return false
+}
+
+// newFlagSet creates a flag set that stores the parsed values into the given args struct.
+func newFlagSet(name string, a *args) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: %s [flags] <glob_pattern1> [glob_pattern2...]\n", name)
+		fs.PrintDefaults()
+	}
+	a.pollInterval = 500 * time.Millisecond
+	fs.Var(pollIntervalFlag{a}, "poll-interval", "Interval to poll files for new content, or adaptive[:min=100ms,max=5s] to adjust it per file to its write rate")
+	fs.DurationVar(&a.scanInterval, "scan-interval", 3*time.Second, "Interval to scan for new files matching glob patterns")
+	fs.DurationVar(&a.dispInterval, "disp-interval", 1*time.Minute, "Interval for showing no files changed")
+	fs.BoolVar(&a.fromStart, "from-start", false, "Read files found at startup from the beginning instead of the end")
+	fs.BoolVar(&a.inclusive, "inclusive-initial", false, "Read files discovered after startup from the beginning if they were modified after startup")
+	fs.BoolVar(&a.stopAtEOF, "stop-at-eof", false, "Exit once all watched files are read to the end and none has grown for eof-grace")
+	fs.DurationVar(&a.eofGrace, "eof-grace", 1*time.Second, "How long all files must stay unchanged at EOF before --stop-at-eof exits")
+	fs.StringVar(&a.serveAddr, "serve", "", "Address (e.g. :8080) to serve the tailed lines to browsers as Server-Sent Events")
+	fs.IntVar(&a.serveBuffer, "serve-buffer", 1024, "Number of lines buffered per --serve client before lines are dropped for that client")
+	fs.BoolVar(&a.sparseProbe, "sparse-probe", false, "Use SEEK_DATA/SEEK_HOLE to ignore holes and zero-filled tails of sparse or preallocated files")
+	fs.Var(&a.include, "include", "Only output lines matching this regular expression (repeatable; a line matching any of them is output)")
+	fs.IntVar(&a.beforeCtx, "before-context", 0, "Number of lines of leading context to output before each --include match")
+	fs.IntVar(&a.afterCtx, "after-context", 0, "Number of lines of trailing context to output after each --include match")
+	fs.Func("context", "Number of lines of leading and trailing context to output around each --include match", func(v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		a.beforeCtx, a.afterCtx = n, n
+		return nil
+	})
+	fs.BoolVar(&a.pollOnly, "poll-only", false, "Do not use filesystem notifications; find new files with the periodic scan only (used automatically if notifications are unavailable)")
+	fs.BoolVar(&a.list, "list", false, "Print the files the glob patterns match, and the skipped candidates with reasons, then exit")
+	fs.BoolVar(&a.buffered, "buffered", false, "Buffer the output and write it once per poll cycle, reducing system calls at the cost of latency")
+	fs.BoolVar(&a.lineBuffered, "line-buffered", false, "Flush the output after every complete line (takes precedence over --buffered)")
+	fs.BoolVar(&a.stripANSI, "strip-ansi", false, "Remove ANSI escape sequences such as colors from each line")
+	fs.BoolVar(&a.redact, "redact", false, "Mask common secrets (AWS keys, bearer tokens, JWTs, URL credentials, password=...) with ***")
+	fs.BoolVar(&a.redactEmails, "redact-emails", false, "Mask email addresses with ***")
+	fs.Var(&a.redactPatterns, "redact-pattern", "Mask matches of this regular expression with *** (repeatable); if it has a capture group, only the first group is masked")
+	fs.BoolVar(&a.count, "count", false, "Instead of the content, output the number of lines (or --include matches) per file every disp-interval and at exit")
+	fs.DurationVar(&a.retryInterval, "retry-interval", 1*time.Second, "Interval to retry watching directories whose watch failed, and to scan them for new files meanwhile (0 disables)")
+	fs.Var(&a.startAfter, "start-after", "Per file, suppress output until a line matching this regular expression is seen, then output the lines after it (repeatable)")
+	fs.BoolVar(&a.startAfterReset, "start-after-reset", false, "Wait for the --start-after marker again after a file is truncated or replaced")
+	fs.IntVar(&a.maxDirWatches, "max-dir-watches", 0, "Maximum number of directories to watch; further ones are only scanned (0 means no limit)")
+	fs.StringVar(&a.framing, "framing", framingNone, "Output framing: none, or length to write each line as a length-prefixed frame with its file path")
+	fs.BoolVar(&a.stripBOM, "strip-bom", false, "Strip a UTF-8 byte order mark at the start of files read from the beginning")
+	fs.IntVar(&a.quietFiles, "quiet-files", 5, "Number of files silent the longest to name in the no-files-changed message (0 names none)")
+	fs.BoolVar(&a.groupByFile, "group-by-file", false, "Collect the content of each file over --group-window and output it as one block per file")
+	fs.DurationVar(&a.groupWindow, "group-window", 1*time.Second, "How long --group-by-file collects content before writing it out")
+	fs.BoolVar(&a.readDevices, "read-devices", false, "Read device files matched by the glob patterns, at most 64 KiB per poll, instead of skipping them")
+	fs.BoolVar(&a.checksumVerify, "checksum-verify", false, "Checksum the start of each file every poll to notice files replaced by others of the same size")
+	fs.BoolVar(&a.strict, "strict", false, "Exit with a nonzero status on the first error that does not resolve by itself, e.g. for CI")
+	fs.StringVar(&a.format, "format", formatRaw, "Output format: raw (content under file headers), json, text (lines prefixed with the file path), or logfmt")
+	fs.Int64Var(&a.maxBytesPerSec, "max-bytes-per-sec", 0, "Maximum number of bytes read from all files combined per second (0 means no limit)")
+	fs.StringVar(&a.onLimit, "on-limit", limitBlock, "What to do when --max-bytes-per-sec is reached: block to read on later without losing data, or drop to drop the lines over the limit")
+	fs.BoolVar(&a.regex, "regex", false, "Take the patterns as regular expressions matched against the absolute paths of the files below --root, instead of glob patterns")
+	fs.StringVar(&a.root, "root", "", "Directory to walk for files matching the --regex patterns")
+	fs.BoolVar(&a.print0, "print0", false, "End each output line with a NUL byte instead of a newline, without file headers, for tools such as xargs -0")
+	fs.StringVar(&a.offsetFrom, "offset-from", "", "Take the initial offset of each file from a companion file with this suffix (e.g. offset for foo.log.offset), if valid")
+	fs.IntVar(&a.readWorkers, "read-workers", 1, "Number of files to read concurrently, so a slow file does not hold up the others")
+	fs.IntVar(&a.maxOpenFDs, "max-open-fds", 0, "Maximum number of watched files open at once (0 means no limit)")
+	fs.BoolVar(&a.hashPaths, "hash-paths", false, "Show file paths in the output as short stable hashes, logging each mapping once to standard error")
+	fs.StringVar(&a.manifest, "manifest", "", "File listing paths of files to follow, one per line, re-read every scan-interval and on SIGHUP")
+	fs.StringVar(&a.output, "output", "", "Write the output to this file instead of standard output, appending if it exists, or to the Unix domain socket of unix://PATH")
+	fs.Var(&a.outputRotateSize, "output-rotate-size", "Rotate the --output file once it reaches this size, e.g. 100MB (0 disables)")
+	fs.DurationVar(&a.outputRotateTime, "output-rotate-time", 0, "Rotate the --output file once it is this old, e.g. 1h (0 disables)")
+	fs.BoolVar(&a.outputRotateGzip, "output-rotate-gzip", false, "Compress rotated --output files with gzip")
+	fs.IntVar(&a.watchSummary, "watch-summary", 20, "Log one summary instead of a line per file and directory if more files than this are found at startup (0 disables)")
+	fs.StringVar(&a.archive, "archive", "", "Read the members of this tar or tar.gz archive matching the glob patterns (all if none are given) from the start, then exit")
+	fs.BoolVar(&a.teeLogs, "tee-stderr-errors-to-output", false, "Also write the log messages (errors, warnings, info) to the output as records in the --format, for consumers of a single stream")
+	fs.DurationVar(&a.outputTimeout, "output-timeout", 0, "Write the output from a separate goroutine and give up on writes the output does not accept within this time (0 writes directly)")
+	fs.StringVar(&a.onOutputError, "on-output-error", onOutputErrorDrop, "What to do when the output blocks for --output-timeout or fails: drop to drop the output and count it, or exit")
+	fs.BoolVar(&a.textOnly, "text-only", false, "Only watch matched files whose first bytes look like text, sniffing them again when read from the start")
+	fs.IntVar(&a.sniffBytes, "sniff-bytes", 512, "Number of bytes at the start of a file sniffed by --text-only")
+	fs.BoolVar(&a.seqno, "seqno", false, "Number the records written to the output with a sequence number increasing across all files, so gaps show lost records")
+	fs.StringVar(&a.batchMarkers, "batch-markers", batchNone, "Mark the output of each poll cycle as a batch: none, text (=== batch N begin/end === lines), json (marker objects), or blank (an empty line after each batch)")
+	fs.BoolVar(&a.batchEmpty, "batch-empty", false, "Also write --batch-markers for poll cycles without output")
+	fs.StringVar(&a.outputTemplate, "output-template", "", "Render each output line with this Go text/template, e.g. '{{.Time.Format \"15:04:05\"}} {{.File}}:{{.LineNo}} {{.Line}}' (fields: File, RealPath, Line, Time, LineNo, Seq, Tag, FileID, Offset, Length, LogTime)")
+	fs.BoolVar(&a.literal, "literal", false, "Take all patterns as literal paths, for files whose names contain glob metacharacters (or prefix single ones with literal:)")
+	fs.Var(&a.maxFileSize, "max-file-size", "Skip files larger than this size, e.g. 1GB, and stop watching files that grow past it (0 means no limit)")
+	fs.Var(&a.minFileSize, "min-file-size", "Skip files smaller than this size, e.g. 1B to skip empty files, until they reach it")
+	fs.Var(&a.sample, "sample", "Output only every Nth line of each file, as 1/N, or only of the files of one pattern, as PATTERN=1/N (repeatable)")
+	fs.IntVar(&a.replayLines, "replay-lines", 10, "Number of last lines of each watched file to output again, marked as a replay, on SIGUSR2")
+	fs.Var(&a.jsonArray, "json-array", "Experimental: follow the files of this glob pattern, as given, as growing JSON arrays, outputting each element as a line (repeatable)")
+	fs.BoolVar(&a.printOffsets, "print-offsets-on-exit", false, "On exit, log the path and final offset of each watched file, so a manual restart can resume from there")
+	fs.StringVar(&a.color, "color", colorAuto, "Highlight --include matches in raw and text output: auto (if standard output is a terminal and NO_COLOR is not set), always, or never")
+	fs.StringVar(&a.mergeBy, "merge-by", mergeNone, "Merge files into one stream under one header, tagging each line with the directory of its file: basename (files of the same base name), or regex (files with the same --merge-regex capture)")
+	fs.StringVar(&a.mergeRegex, "merge-regex", "", "With --merge-by regex, the regular expression on the path whose first capture group names the stream of a file")
+	fs.StringVar(&a.minLevel, "min-level", "", "Only output lines of at least this severity, e.g. warn, detected with --level-regex; lines without a level inherit the one of the last line of their file that had one")
+	fs.StringVar(&a.levelRegex, "level-regex", defaultLevelRegex, "Regular expression whose first capture group is the level of a line, for --min-level")
+	fs.StringVar(&a.levelOrder, "levels", defaultLevels, "Levels known to --min-level, from least to most severe, separated by commas; synonyms are separated by |")
+	fs.BoolVar(&a.keepUnleveled, "keep-unleveled", true, "With --min-level, output the lines of a file before its first line with a level")
+	fs.StringVar(&a.pauseMode, "pause-mode", pauseHold, "What pausing with SIGUSR1 does: hold (stop reading, and catch up on resume) or drop (keep reading, and drop the content until resumed)")
+	a.delim = delimiterFlag("\n")
+	fs.Var(&a.delim, "record-delimiter", "Bytes ending each record of a file instead of a newline, with Go escapes such as \\f or \\x00; records are filtered and formatted like lines")
+	fs.BoolVar(&a.emitFileID, "emit-file-id", false, "Show the identity of each file (device:inode on Unix) with its content: in the file headers, after the path with --format text, or as file_id with json and logfmt")
+	fs.Var(&a.checkpoint, "checkpoint", "RFC 3339 time, e.g. of the last run: files found at startup modified since then are read from the start, the others from the end")
+	fs.Var(&a.onMatch, "on-match", "Run a command for each line matching a regular expression, as REGEXP:COMMAND, with the file and the line as its last arguments and the line on standard input (repeatable)")
+	fs.IntVar(&a.onMatchWorkers, "on-match-workers", 2, "Maximum number of --on-match commands running at the same time")
+	fs.Int64Var(&a.onMatchRate, "on-match-rate", 1, "Maximum number of --on-match commands started per second; further matches are dropped and counted")
+	fs.DurationVar(&a.onMatchTimeout, "on-match-timeout", 10*time.Second, "Time after which an --on-match command is killed")
+	fs.DurationVar(&a.dedupWindow, "dedup-window", 0, "Suppress lines identical to one output from the same file within this duration, e.g. 1m, logging how many were suppressed (0 disables)")
+	fs.IntVar(&a.dedupMax, "dedup-max", 10000, "Maximum number of distinct lines remembered by --dedup-window; the least recently seen are forgotten first")
+	fs.BoolVar(&a.dedupAcrossFiles, "dedup-across-files", false, "With --dedup-window, also suppress lines identical to one output from another file")
+	fs.BoolVar(&a.markLive, "mark-live", false, "Write a --- path LIVE --- line once a file read from before its end, e.g. with --from-start, has caught up and further content is appended live")
+	fs.StringVar(&a.listenUnix, "listen-unix", "", "Serve the output on a Unix domain socket at this path, to every connected consumer, instead of writing it to standard output")
+	a.listenUnixMode = 0o600
+	fs.Var(&a.listenUnixMode, "listen-unix-mode", "Permissions of the --listen-unix socket, in octal")
+	fs.BoolVar(&a.emitOffsets, "emit-offsets", false, "Annotate each line output with --format json or logfmt, or --output-template, with its byte offset and length in its file")
+	fs.StringVar(&a.ignoreFile, "ignore-file", defaultIgnoreFile, "Name of the gitignore-style files excluding files below the directories they are in from being watched (empty disables)")
+	fs.BoolVar(&a.statusLine, "status-line", false, "Keep a line with the number of watched files, the throughput, and the file written last updated in place on standard error, if it and standard output are terminals")
+	fs.BoolVar(&a.progress, "progress", false, "With --stop-at-eof, log the percentage read and the ETA of each file every --progress-interval")
+	fs.DurationVar(&a.progressInterval, "progress-interval", 5*time.Second, "How often --progress logs")
+	fs.Var(&a.diffMode, "diff-mode", "Experimental: follow the files of this glob pattern, as given, as files rewritten in full, outputting only the lines a new version adds to the one it replaced (repeatable)")
+	a.diffMaxSize = defaultDiffMaxSize
+	fs.Var(&a.diffMaxSize, "diff-max-size", "Size up to which --diff-mode keeps the content of a file to compare the next version with, e.g. 1MiB; larger versions are followed by the next one in full")
+	fs.BoolVar(&a.integrityFooter, "integrity-footer", false, "When a file is rotated away or removed, and at exit, output a footer with the bytes, lines, and checksum of the content output for it")
+	fs.StringVar(&a.integrityHash, "integrity-hash", integrityCRC32, "Checksum of --integrity-footer: crc32, crc64, or sha256")
+	fs.StringVar(&a.pathStyle, "path-style", pathAbsolute, "How file paths are shown in the output, whatever the base directory of the pattern matching them: absolute, or relative to the working directory")
+	fs.IntVar(&a.maxLineRate, "max-line-rate-per-file", 0, "Lines per second above which a file is sampled, outputting only every --rate-sample-ratio line until its rate drops again (0 disables)")
+	fs.IntVar(&a.rateSampleRatio, "rate-sample-ratio", 10, "With --max-line-rate-per-file, output every Nth line of a file over the rate")
+	fs.BoolVar(&a.watchDirEvents, "watch-dir-events", false, "Instead of the content, output a record each time a file matching the glob patterns is created in or removed from a watched directory")
+	fs.DurationVar(&a.minFileAge, "min-file-age", 0, "Only watch files found after startup once they have existed for this long, e.g. 5s, skipping transient files (0 disables)")
+	fs.BoolVar(&a.autoTimestamp, "auto-timestamp", false, "Detect the timestamp format of each file from its first lines and output the timestamp of each line as log_time (requires --format json or logfmt, or --output-template)")
+	fs.StringVar(&a.watchOps, "watch-ops", defaultWatchOps, "Comma-separated directory watcher operations to act on: create, write, remove, rename, chmod; files whose events are ignored are still found and dropped by the scan")
+	fs.StringVar(&a.logFormat, "log-format", logFormatText, "Format of ftail's own log messages on standard error: text, or slog-json for a JSON record per message with time, level, and msg, as log/slog writes them")
+	fs.Var(&a.tailBytes, "tail-bytes", "Start files found at startup at the first line in their last this many bytes, e.g. 4KB, instead of at their end (0 starts at the end)")
+	fs.BoolVar(&a.traceEvents, "trace-events", false, "Log every raw directory watcher event and every scan decision, with microsecond timestamps, to diagnose files not being detected on a filesystem")
+	fs.Var(&a.maxBufferMemory, "max-buffer-memory", "Most memory all files together may buffer between reads, e.g. 64MB, in incomplete lines, context lines, and --diff-mode content, evicting the largest buffers first when exceeded (0 means no limit)")
+	fs.StringVar(&a.bufferPolicy, "buffer-memory-policy", bufferPolicyFlush, "What --max-buffer-memory does with an incomplete line it evicts: flush outputs it as it is, drop discards it with the rest of the line")
+	fs.BoolVar(&a.firstMatch, "first-match", false, "Watch only the first file each glob pattern matches, by --first-match-by, choosing again at every scan")
+	fs.StringVar(&a.firstMatchBy, "first-match-by", firstMatchNewest, "Which file --first-match watches: newest, the most recently modified, or name, the first by path")
+	fs.BoolVar(&a.reverse, "reverse", false, "With --stop-at-eof, output the lines of the files found at startup newest first, from their end back to their start, or to --tail-bytes")
+	fs.BoolVar(&a.squeezeBlank, "squeeze-blank", false, "Output only the first of consecutive blank lines of a file, like cat -s")
+	fs.StringVar(&a.splitOutput, "split-output", "", "Write the content of each watched file to a file of its own under this directory, at its path relative to the base directory of its glob pattern, instead of to the output")
+	fs.IntVar(&a.splitOutputMaxOpen, "split-output-max-open", defaultSplitOutputMaxOpen, "Most --split-output files kept open at once; the least recently written is closed to open another")
+	fs.Var(&a.warnOnGap, "warn-on-gap", "Warn when more than this much of a file, e.g. 1GB, is to be read at once, as a sign of corruption or a misconfigured writer (0 disables)")
+	fs.StringVar(&a.onGap, "on-gap", gapWarn, "What --warn-on-gap does beyond warning: warn reads the content anyway, skip skips to the end of the file")
+	fs.BoolVar(&a.recursive, "recursive", false, "Follow the files in subdirectories of directories given as patterns as well, like DIR/** instead of DIR/*")
+	fs.StringVar(&a.stateFile, "state-file", "", "Save the offset of each watched file to this file every state-interval and on exit, and resume the files from it at startup")
+	fs.DurationVar(&a.stateInterval, "state-interval", 10*time.Second, "Interval to save the --state-file")
+	fs.DurationVar(&a.stateTTL, "state-ttl", 7*24*time.Hour, "Drop the --state-file entries of files that are gone and were last watched longer ago than this (0 keeps them)")
+	fs.StringVar(&a.sshCommand, "ssh-command", "ssh", "Command, with its arguments, run to connect to the host of sftp://[user@]host[:port]/path patterns")
+	fs.BoolVar(&a.debugOffsets, "debug-offsets", false, "Log the stored offset and on-disk size of each watched file every disp-interval")
+	fs.StringVar(&a.configPath, "config", "", "Path to a file with additional flags and glob patterns, one per line (re-read on SIGHUP)")
+	return fs
+}
+
+// parseArgs parses the command-line arguments and, if --config is given, merges them with the config file.
+// The config file is applied first so that flags on the command line override it.
+// The glob patterns are those of the config file followed by those of the command line.
+func parseArgs(name string, arguments []string) (*args, error) {
+	cli := &args{}
+	fs := newFlagSet(name, cli)
+	if err := fs.Parse(arguments); err != nil {
+		return nil, err
+	}
+	if cli.configPath == "" {
+		cli.patterns = literalPatterns(fs.Args(), cli.literal)
+		cli.setCmdLine(fs, name, arguments)
+		return cli, nil
+	}
+
+	flagArgs, patterns, err := readConfigFile(cli.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &args{}
+	fs = newFlagSet(name, merged)
+	if err = fs.Parse(flagArgs); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", cli.configPath, err)
+	}
+	if err = fs.Parse(arguments); err != nil {
+		return nil, err
+	}
+	// The config file path always comes from the command line, so a reload reads the same file.
+	merged.configPath = cli.configPath
+	merged.patterns = literalPatterns(append(patterns, fs.Args()...), merged.literal)
+	merged.setCmdLine(fs, name, arguments)
+	return merged, nil
+}
+
+// setCmdLine records the command line c was parsed from with fs, see cmdArgs and flagValues.
+func (c *args) setCmdLine(fs *flag.FlagSet, name string, arguments []string) {
+	c.cmdName, c.cmdArgs = name, arguments
+	c.flagValues = make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		c.flagValues[f.Name] = f.Value.String()
+	})
+}
+
+// validate checks the parsed arguments for values that cannot work, and returns an error for them.
+// Combinations that work but are likely mistakes are only logged as warnings.
+func (c *args) validate() error {
+	if c.archive != "" && (c.regex || c.manifest != "" || c.list || c.serveAddr != "") {
+		return errors.New("--archive cannot be combined with --regex, --manifest, --list, or --serve")
+	}
+	if remote, patterns, err := splitRemotePatterns(c.patterns); err != nil {
+		return err
+	} else if remote != (sftpTarget{}) {
+		if c.regex || c.manifest != "" || c.archive != "" {
+			return errors.New("sftp:// patterns cannot be combined with --regex, --manifest, or --archive")
+		}
+		c.remote, c.patterns = remote, patterns
+	}
+	if c.regex && c.literal {
+		return errors.New("--literal cannot be combined with --regex")
+	}
+	if c.regex && c.manifest != "" {
+		return errors.New("--manifest cannot be combined with --regex")
+	}
+	if c.regex {
+		if c.root == "" {
+			return errors.New("--regex needs --root, the directory to walk for matching files")
+		}
+		for _, p := range c.patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", p, err)
+			}
+		}
+	} else if err := validatePatterns(c.patterns); err != nil {
+		return err
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"poll-interval", c.pollInterval},
+		{"scan-interval", c.scanInterval},
+		{"state-interval", c.stateInterval},
+	} {
+		if d.value <= 0 {
+			return fmt.Errorf("--%s must be positive, got %v", d.name, d.value)
+		}
+	}
+	if c.dispInterval < 0 {
+		return fmt.Errorf("--disp-interval must not be negative, got %v", c.dispInterval)
+	}
+	if c.stateTTL < 0 {
+		return fmt.Errorf("--state-ttl must not be negative, got %v", c.stateTTL)
+	}
+	if err := validFraming(c.framing); err != nil {
+		return err
+	}
+	if _, err := newFormatter(c.format); err != nil {
+		return err
+	}
+	if c.outputTemplate != "" {
+		if _, err := newTemplateFormatter(c.outputTemplate); err != nil {
+			return err
+		}
+		if c.format != formatRaw || c.framing == framingLength || c.print0 || c.count {
+			return errors.New("--output-template cannot be combined with --format, --framing length, --print0, or --count")
+		}
+	}
+	if c.framing == framingLength && c.format != formatRaw {
+		return errors.New("--framing length cannot be combined with --format")
+	}
+	if c.print0 && (c.count || c.framing == framingLength) {
+		return errors.New("--print0 cannot be combined with --count or --framing length")
+	}
+	if c.framing == framingLength && c.count {
+		return errors.New("--framing length cannot be combined with --count")
+	}
+	if c.maxBytesPerSec < 0 {
+		return fmt.Errorf("--max-bytes-per-sec must not be negative, got %d", c.maxBytesPerSec)
+	}
+	if err := validLimitMode(c.onLimit); err != nil {
+		return err
+	}
+	if c.maxOpenFDs < 0 {
+		return fmt.Errorf("--max-open-fds must not be negative, got %d", c.maxOpenFDs)
+	}
+	if c.readWorkers < 1 {
+		return fmt.Errorf("--read-workers must be at least 1, got %d", c.readWorkers)
+	}
+	if c.watchSummary < 0 {
+		return fmt.Errorf("--watch-summary must not be negative, got %d", c.watchSummary)
+	}
+	if c.maxFileSize > 0 && c.minFileSize > c.maxFileSize {
+		return fmt.Errorf("--min-file-size %d is larger than --max-file-size %d", c.minFileSize, c.maxFileSize)
+	}
+	if c.replayLines < 0 {
+		return fmt.Errorf("--replay-lines must not be negative, got %d", c.replayLines)
+	}
+	if c.sniffBytes <= 0 {
+		return fmt.Errorf("--sniff-bytes must be positive, got %d", c.sniffBytes)
+	}
+	if c.quietFiles < 0 {
+		return fmt.Errorf("--quiet-files must not be negative, got %d", c.quietFiles)
+	}
+	if c.groupByFile && c.groupWindow <= 0 {
+		return fmt.Errorf("--group-window must be positive, got %v", c.groupWindow)
+	}
+	if c.maxDirWatches < 0 {
+		return fmt.Errorf("--max-dir-watches must not be negative, got %d", c.maxDirWatches)
+	}
+	if c.retryInterval < 0 {
+		return fmt.Errorf("--retry-interval must not be negative, got %v", c.retryInterval)
+	}
+	if c.eofGrace < 0 {
+		return fmt.Errorf("--eof-grace must not be negative, got %v", c.eofGrace)
+	}
+	if c.beforeCtx < 0 || c.afterCtx < 0 {
+		return fmt.Errorf("context line counts must not be negative")
+	}
+
+	if _, err := validMerge(c.mergeBy, c.mergeRegex); err != nil {
+		return err
+	}
+	if c.mergeBy != mergeNone && c.count {
+		return errors.New("--merge-by cannot be combined with --count")
+	}
+	if err := c.validLevels(); err != nil {
+		return err
+	}
+	if err := validPauseMode(c.pauseMode); err != nil {
+		return err
+	}
+	if string(c.delim) != "\n" && len(c.jsonArray) > 0 {
+		return errors.New("--record-delimiter cannot be combined with --json-array")
+	}
+	if !c.checkpoint.IsZero() && c.fromStart {
+		return errors.New("--checkpoint cannot be combined with --from-start")
+	}
+	if strings.ContainsAny(c.ignoreFile, `/\`) || c.ignoreFile == "." || c.ignoreFile == ".." {
+		return fmt.Errorf("--ignore-file must be a file name, got %q", c.ignoreFile)
+	}
+	if c.tailBytes > 0 && c.fromStart {
+		return errors.New("--tail-bytes cannot be combined with --from-start")
+	}
+	if c.reverse {
+		if !c.stopAtEOF {
+			return errors.New("--reverse requires --stop-at-eof, as lines appended while following cannot go before those already output")
+		}
+		if len(c.jsonArray) > 0 || len(c.diffMode) > 0 || c.progress {
+			return errors.New("--reverse cannot be combined with --json-array, --diff-mode, or --progress")
+		}
+	}
+	if c.progress && !c.stopAtEOF {
+		return errors.New("--progress requires --stop-at-eof, as following files never completes")
+	}
+	if c.progressInterval <= 0 {
+		return fmt.Errorf("--progress-interval must be positive, got %v", c.progressInterval)
+	}
+	for _, p := range c.diffMode {
+		if slices.Contains(c.jsonArray, p) {
+			return fmt.Errorf("--diff-mode and --json-array cannot both name the pattern %s", p)
+		}
+	}
+	if c.diffMaxSize < 0 {
+		return fmt.Errorf("--diff-max-size must not be negative, got %d", c.diffMaxSize)
+	}
+	if _, err := newIntegrityHash(c.integrityHash); err != nil {
+		return err
+	}
+	if c.integrityFooter && (c.framing == framingLength || c.outputTemplate != "" || c.count) {
+		return errors.New("--integrity-footer cannot be combined with --framing length, --output-template, or --count")
+	}
+	if c.pathStyle != pathAbsolute && c.pathStyle != pathRelative {
+		return fmt.Errorf("--path-style must be %s or %s, got %q", pathAbsolute, pathRelative, c.pathStyle)
+	}
+	if c.minFileAge < 0 {
+		return fmt.Errorf("--min-file-age must not be negative, got %v", c.minFileAge)
+	}
+	if c.maxLineRate < 0 {
+		return fmt.Errorf("--max-line-rate-per-file must not be negative, got %d", c.maxLineRate)
+	}
+	if c.rateSampleRatio < 1 {
+		return fmt.Errorf("--rate-sample-ratio must be at least 1, got %d", c.rateSampleRatio)
+	}
+	if c.watchDirEvents && (c.framing == framingLength || c.outputTemplate != "" || c.count || c.groupByFile) {
+		return errors.New("--watch-dir-events cannot be combined with --framing length, --output-template, --count, or --group-by-file")
+	}
+	if c.autoTimestamp {
+		if c.format != "json" && c.format != "logfmt" && c.outputTemplate == "" {
+			return fmt.Errorf("--auto-timestamp requires --format json or logfmt, or --output-template")
+		}
+		if c.groupByFile || len(c.jsonArray) > 0 {
+			return fmt.Errorf("--auto-timestamp cannot be combined with --group-by-file or --json-array")
+		}
+	}
+	if _, err := parseWatchOps(c.watchOps); err != nil {
+		return err
+	}
+	if err := validLogFormat(c.logFormat); err != nil {
+		return err
+	}
+	if err := validBufferPolicy(c.bufferPolicy); err != nil {
+		return err
+	}
+	if err := validFirstMatchBy(c.firstMatchBy); err != nil {
+		return err
+	}
+	if c.splitOutput != "" {
+		if c.groupByFile || c.framing == framingLength || c.batchMarkers != batchNone {
+			return errors.New("--split-output cannot be combined with --group-by-file, --framing length, or --batch-markers")
+		}
+		if c.splitOutputMaxOpen < 1 {
+			return fmt.Errorf("--split-output-max-open must be at least 1, got %d", c.splitOutputMaxOpen)
+		}
+	}
+	if err := validGapAction(c.onGap); err != nil {
+		return err
+	}
+	if c.emitOffsets {
+		if c.format != "json" && c.format != "logfmt" && c.outputTemplate == "" {
+			return errors.New("--emit-offsets requires --format json or logfmt, or --output-template")
+		}
+		if c.groupByFile || len(c.jsonArray) > 0 || len(c.diffMode) > 0 {
+			return errors.New("--emit-offsets cannot be combined with --group-by-file, --json-array, or --diff-mode")
+		}
+	}
+	if c.dedupWindow < 0 {
+		return fmt.Errorf("--dedup-window must not be negative, got %v", c.dedupWindow)
+	}
+	if c.dedupMax < 1 {
+		return fmt.Errorf("--dedup-max must be at least 1, got %d", c.dedupMax)
+	}
+	if c.onMatchWorkers < 1 {
+		return fmt.Errorf("--on-match-workers must be at least 1, got %d", c.onMatchWorkers)
+	}
+	if c.onMatchRate < 1 {
+		return fmt.Errorf("--on-match-rate must be at least 1, got %d", c.onMatchRate)
+	}
+	if c.onMatchTimeout <= 0 {
+		return fmt.Errorf("--on-match-timeout must be positive, got %v", c.onMatchTimeout)
+	}
+	if err := validColor(c.color); err != nil {
+		return err
+	}
+
+	switch c.batchMarkers {
+	case batchNone, batchText, batchJSON, batchBlank:
+	default:
+		return fmt.Errorf("--batch-markers must be %s, %s, %s, or %s, got %q", batchNone, batchText, batchJSON, batchBlank, c.batchMarkers)
+	}
+	if c.batchMarkers != batchNone && c.framing == framingLength {
+		return errors.New("--batch-markers cannot be combined with --framing length")
+	}
+	if c.outputTimeout < 0 {
+		return fmt.Errorf("--output-timeout must not be negative, got %v", c.outputTimeout)
+	}
+	if c.onOutputError != onOutputErrorDrop && c.onOutputError != onOutputErrorExit {
+		return fmt.Errorf("--on-output-error must be %s or %s, got %q", onOutputErrorDrop, onOutputErrorExit, c.onOutputError)
+	}
+	if c.onOutputError != onOutputErrorDrop && c.outputTimeout == 0 {
+		return errors.New("--on-output-error requires --output-timeout")
+	}
+	if c.outputRotateTime < 0 {
+		return fmt.Errorf("--output-rotate-time must not be negative, got %v", c.outputRotateTime)
+	}
+	_, unixOutput := unixOutputPath(c.output)
+	if (c.output == "" || unixOutput) && (c.outputRotateSize > 0 || c.outputRotateTime > 0 || c.outputRotateGzip) {
+		return fmt.Errorf("--output-rotate-size, --output-rotate-time, and --output-rotate-gzip require an --output file")
+	}
+	if c.listenUnix != "" && c.output != "" {
+		return errors.New("--listen-unix cannot be combined with --output")
+	}
+
+	if c.buffered && c.lineBuffered {
+		log.Printf("Warning: --line-buffered takes precedence over --buffered\n")
+		c.buffered = false
+	}
+
+	if c.pollInterval > c.scanInterval {
+		log.Printf("Warning: --poll-interval %v is larger than --scan-interval %v; new files are found faster than they are read\n", c.pollInterval, c.scanInterval)
+	}
+	return nil
+}
+
+// readConfigFile reads flags and glob patterns from a config file.
+// Each non-empty line that does not start with '#' is either a flag ("--name=value" or "--name value")
+// or a glob pattern.
+func readConfigFile(path string) (flagArgs []string, patterns []string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			patterns = append(patterns, line)
+			continue
+		}
+		// Split "--name value" into two arguments, keeping any spaces inside the value.
+		name, value, found := strings.Cut(line, " ")
+		flagArgs = append(flagArgs, name)
+		if found {
+			flagArgs = append(flagArgs, strings.TrimSpace(value))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return flagArgs, patterns, nil
 }
 
 // main is the entry point of the application.
 func main() {
-	flag.Parse()
+	cfg, err := parseArgs(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+		if hint := flagHint(err); hint != "" {
+			log.Printf("Info: %s\n", hint)
+		}
+		os.Exit(2)
+	}
 
-	// Initialize the application state with a reference to the global args struct.
-	a := &app{
-		globPatterns: flag.Args(),
-		args:         &theArgs, // Embed the global args struct by reference
+	if len(cfg.patterns) < 1 && cfg.manifest == "" && cfg.archive == "" {
+		fs := newFlagSet(os.Args[0], &args{})
+		fs.SetOutput(os.Stderr)
+		fs.Usage()
+		log.Printf("Info: no glob patterns given; give at least one, quoted so the shell does not expand it, e.g. '/var/log/*.log'\n")
+		os.Exit(1)
+	}
+	// Patterns from a config file are not subject to the shell, so only check those of the command line.
+	for _, hint := range patternHints(cfg.patterns, cfg.configPath == "") {
+		log.Printf("Warning: %s\n", hint)
 	}
 
-	if len(a.globPatterns) < 1 {
-		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [flags] <glob_pattern1> [glob_pattern2...]\n", os.Args[0])
-		flag.PrintDefaults()
+	// Stop gracefully on Ctrl+C or SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = run(ctx, cfg); err != nil {
+		log.Printf("Error: %v\n", err)
+		stop()
 		os.Exit(1)
 	}
+}
+
+// run tails the files matching the glob patterns of cfg until ctx is canceled
+// or, with --stop-at-eof, until all files have been read to the end.
+func run(ctx context.Context, cfg *args) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	// With --strict, an error stops ftail through this context, see logError.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	a := newApp(cfg, cancel)
+	if a.matches != nil {
+		defer a.matches.close()
+	}
+	if src, ok := a.source.(*sftpSource); ok {
+		defer src.close()
+	}
+	if err := a.openState(); err != nil {
+		return err
+	}
+
+	// Content goes to standard output, the --output file or socket, or the --listen-unix consumers.
+	var w io.Writer = os.Stdout
+	// Log messages go to standard error.
+	var stderr io.Writer = os.Stderr
+	if a.statusLineEnabled() {
+		// The content and the log messages erase the status line before they are written over it.
+		a.status = newStatusLine(os.Stderr)
+		w = statusClearer{w: os.Stdout, status: a.status}
+		stderr = statusClearer{w: os.Stderr, status: a.status}
+		log.SetOutput(stderr)
+		defer log.SetOutput(os.Stderr)
+		defer a.status.clear()
+	} else if a.statusLine {
+		log.Printf("Warning: --status-line is only shown if standard output and standard error are terminals.\n")
+	}
+	if a.logFormat == logFormatSlogJSON {
+		stderr = newSlogWriter(stderr)
+		log.SetOutput(stderr)
+		defer log.SetOutput(os.Stderr)
+	}
+	if path, ok := unixOutputPath(a.output); ok {
+		uc := newUnixClient(path)
+		defer func() { _ = uc.Close() }()
+		w = uc
+	} else if a.listenUnix != "" {
+		ul, err := listenUnix(a.listenUnix, os.FileMode(a.listenUnixMode))
+		if err != nil {
+			return err
+		}
+		defer func() { _ = ul.Close() }()
+		w = ul
+	} else if a.output != "" {
+		// Rotation waits for line ends in line mode, so no line is split across files.
+		rf, err := openRotatingFile(a.output, int64(a.outputRotateSize), a.outputRotateTime, a.outputRotateGzip, a.lineMode())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rf.Close() }()
+		w = rf
+	}
+	if a.outputTimeout > 0 {
+		// The guard is closed before the file behind it, flushing what is queued for it.
+		g := newGuardedWriter(w, a.outputTimeout, a.onOutputError, func(err error) {
+			a.fatalOnce.Do(func() {
+				a.fatalErr = fmt.Errorf("stopping on output failure (--on-output-error %s): %w", onOutputErrorExit, err)
+				a.cancel()
+			})
+		})
+		defer g.Close()
+		w = g
+	}
+	a.out = newOutput(w)
+	if a.splitOutput != "" {
+		a.split = newSplitOutputs(a.splitOutput, a.splitOutputMaxOpen)
+	}
+	if a.teeLogs {
+		// Standard error keeps getting every message, so interactive use is unaffected.
+		a.logTee = &logTee{}
+		log.SetOutput(io.MultiWriter(stderr, a.logTee))
+		defer log.SetOutput(os.Stderr)
+	}
+
+	// With --list, only print what the patterns match.
+	if a.list {
+		return a.listMatches()
+	}
+
+	// With --archive, read the archive instead of following files.
+	if a.archive != "" {
+		return a.readArchive(ctx)
+	}
 
 	// Create a new filesystem watcher for directory events (create, rename, delete).
-	var err error
-	a.dirWatcher, err = fsnotify.NewWatcher()
-	if err != nil {
-		log.Printf("Error: creating directory watcher: %v\n", err)
-		return
+	// If the platform does not support it, fall back to finding new files by scanning only.
+	if !a.pollOnly && !a.discovery.Watchable() {
+		a.pollOnly = true
+	}
+	if !a.pollOnly {
+		var err error
+		a.dirWatcher, err = fsnotify.NewWatcher()
+		if err != nil && a.strict {
+			return fmt.Errorf("%w: %v", errStrictWatcher, err)
+		}
+		if err != nil {
+			log.Printf("Warning: creating directory watcher: %v; falling back to --poll-only\n", err)
+			a.pollOnly = true
+		} else {
+			// Ensure the watcher is closed when run returns.
+			defer func() { _ = a.dirWatcher.Close() }()
+		}
 	}
-	// Ensure the watcher is closed when the main function exits.
-	defer func() { _ = a.dirWatcher.Close() }()
 
 	// Set up the initial set of files to watch based on glob patterns.
-	a.setupWatchers()
+	a.setupWatchers(true)
 
 	// Start a goroutine to handle filesystem events from the directory watcher.
-	go a.handleDirEvents()
+	if !a.pollOnly {
+		go a.handleDirEvents()
+	}
+
+	// Start the HTTP server streaming the emitted lines, if requested.
+	if a.serveAddr != "" {
+		a.streams = newBroadcaster(a.serveBuffer)
+		go a.serve()
+	}
 
 	// Start a goroutine to poll for file content changes and print to stdout.
-	go a.pollFiles()
+	// It stops when ctx is canceled, and closes a.done when it has finished its output.
+	go a.pollFiles(ctx)
 
 	// Start a goroutine to periodically scan for new files matching glob patterns.
 	go a.scanForNewFiles()
 
-	// Block the main goroutine indefinitely to keep the program running.
-	// It will only exit when a signal (e.g., Ctrl+C) is received.
-	select {}
+	// Start a goroutine to reload the configuration when SIGHUP is received.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+	go a.handleReloadSignals(sighup)
+
+	// Start a goroutine to replay the last lines of the watched files on SIGUSR2, where available.
+	if len(replaySignals) > 0 && a.replayLines > 0 {
+		replay := make(chan os.Signal, 1)
+		signal.Notify(replay, replaySignals...)
+		defer signal.Stop(replay)
+		go a.handleReplaySignals(replay)
+	}
+
+	// Start a goroutine to pause and resume following on SIGUSR1, where available.
+	if len(pauseSignals) > 0 {
+		pause := make(chan os.Signal, 1)
+		signal.Notify(pause, pauseSignals...)
+		defer signal.Stop(pause)
+		go a.handlePauseSignals(pause)
+	}
+
+	// Block until a signal (e.g., Ctrl+C) is received or --stop-at-eof is satisfied,
+	// and the polling goroutine has written its final output.
+	<-a.done
+	return a.fatalErr
+}
+
+// newApp initializes the application state with the validated args cfg. cancel stops ftail,
+// see logError. The output and the watchers are set up by run.
+func newApp(cfg *args, cancel context.CancelFunc) *app {
+	a := &app{
+		pollReload:      make(chan *args),
+		scanReload:      make(chan *args),
+		done:            make(chan struct{}),
+		replay:          make(chan struct{}, 1),
+		symlinkRescan:   make(chan struct{}, 1),
+		newIdleTicker:   idleTicker,
+		startTime:       time.Now(),
+		workDir:         workDir(),
+		remountInterval: cfg.scanInterval,
+		cancel:          cancel,
+		source:          localSource{},
+		discovery:       localSource{},
+		args:            cfg,
+	}
+	if cfg.remote != (sftpTarget{}) {
+		src := newSFTPSource(cfg.remote, dialSSH(cfg.sshCommand, cfg.remote))
+		a.source, a.discovery = src, src
+	}
+	a.setPatterns(cfg.patterns)
+	a.refreshManifest(a.manifest, cfg.patterns)
+	a.formatter, _ = newFormatter(a.format)
+	if a.outputTemplate != "" {
+		a.lineTemplate, _ = newTemplateFormatter(a.outputTemplate)
+	}
+	if a.maxOpenFDs > 0 {
+		a.fds = make(chan struct{}, a.maxOpenFDs)
+	}
+	if a.maxBytesPerSec > 0 {
+		a.limiter = newThroughputLimiter(a.maxBytesPerSec)
+	}
+	if a.watchDirEvents {
+		a.dirEvents = &dirEvents{}
+	}
+	if a.integrityFooter {
+		a.integrity = newIntegrityFooters(a.integrityHash)
+	}
+	if a.progress {
+		a.progressLog = newProgressTracker(a.progressInterval)
+	}
+	if a.ignoreFile != "" {
+		a.ignores = newIgnoreCache(a.ignoreFile, a.discovery)
+	}
+	if a.dedupWindow > 0 {
+		a.dedup = newDedupFilter(a.dedupWindow, a.dedupMax, a.dedupAcrossFiles)
+	}
+	if len(a.onMatch) > 0 {
+		a.matches = newMatchRunner(a.onMatchWorkers, a.onMatchRate, a.onMatchTimeout)
+	}
+	if a.stripANSI {
+		a.addLineTransform(stripANSI)
+	}
+	if a.redact || a.redactEmails || len(a.redactPatterns) > 0 {
+		a.addLineTransform(newRedactor(a.redact, a.redactEmails, a.redactPatterns))
+	}
+	a.watchOpMask, _ = parseWatchOps(a.watchOps)
+	if a.maxBufferMemory > 0 {
+		a.bufferMemory = &bufferAccountant{}
+	}
+	if a.minLevel != "" {
+		a.levels, _ = newLevelFilter(a.minLevel, a.levelOrder, a.levelRegex, a.keepUnleveled)
+	}
+	if a.mergeBy != mergeNone {
+		a.mergeRe, _ = validMerge(a.mergeBy, a.mergeRegex)
+		a.addLineTransform(a.mergeTag)
+	}
+	if a.highlightMatches() {
+		// Last, so the highlights are not stripped or redacted.
+		a.addLineTransform(newHighlighter(a.include))
+	}
+	return a
 }
 
 // setupWatchers initializes the list of files to be watched and sets their initial read offsets.
 // It also adds the root directories of the glob patterns to the directory watcher.
-func (a *app) setupWatchers() {
+// initial is true only for the first call at startup.
+func (a *app) setupWatchers(initial bool) {
 	// Use local maps to keep track of newly added files and directories during this run
-	// before updating the main app state.
+	// before updating the main app state. They hold the union of the matches of all patterns,
+	// so a file matched by several patterns is kept as long as any of them still matches it.
 	newlyAddedFiles := make(map[string]bool)
 	newlyAddedDirs := make(map[string]bool)
+	a.pruneYoungFiles()
+	// Read the ignore files again, in case they changed.
+	if a.ignores != nil {
+		a.ignores.reset()
+	}
+	if initial && a.watchSummary > 0 {
+		a.startupWatches = &startupWatches{}
+		defer a.logStartupWatches()
+	}
 
-	err := a.globWalk(func(realPath string) error {
-		// Add the parent directory to the directory watcher.
+	watch := func(realPath, pattern string) {
+		// Add the parent directory to the directory watcher. It is kept even if the watch failed,
+		// so it stays recorded for retrying and scanning.
 		realDir := filepath.Dir(realPath)
-		if added := a.addToWatchDir(realDir); added {
-			newlyAddedDirs[realDir] = true
-		}
+		a.addToWatchDir(realDir)
+		newlyAddedDirs[realDir] = true
 
 		// Add the file to the watch list.
-		if added := a.addToWatchFile(realPath); added {
+		added := a.addToWatchFile(realPath, pattern, initial)
+		if added {
 			newlyAddedFiles[realPath] = true
 		}
-
+		a.tracef("scan matches %s (pattern %s), watched: %t\n", realPath, pattern, added)
+	}
+	// With --first-match, the matches of each pattern are collected, and only the first is watched.
+	matches := firstMatches{source: a.source}
+	notFirst := make(map[string]bool)
+	err := a.globWalk(func(realPath, pattern string) error {
+		if a.firstMatch {
+			matches.add(realPath, pattern)
+		} else {
+			watch(realPath, pattern)
+		}
 		return nil
-	})
+	}, a.traceWalkNote)
+	for _, pattern := range matches.patterns {
+		first := matches.first(pattern, a.firstMatchBy)
+		watch(first, pattern)
+		for _, c := range matches.files[pattern] {
+			if c.path != first {
+				notFirst[c.path] = true
+				a.traceWalkNote(walkNote{path: c.path, pattern: pattern, reason: "not the first match (--first-match)", skipped: true})
+			}
+		}
+	}
 	if err != nil {
-		log.Printf("Error: %v\n", err)
+		// The matches of a failed pattern are incomplete, so removing files missing from them
+		// could stop watching files that still match. Keep everything until a complete walk.
+		// A source that cannot be reached logs losing its connection itself.
+		if !errors.Is(err, errSourceUnavailable) {
+			a.logError("%v\n", err)
+			log.Printf("Warning: Not removing unmatched files, as the scan was incomplete.\n")
+		}
+		return
 	}
 
-	// Remove files that no longer match the glob pattern.
+	// Remove files that no longer match any glob pattern.
 	a.watchedFiles.Range(func(key, _ interface{}) bool {
 		path := key.(string)
 		if _, ok := newlyAddedFiles[path]; !ok {
+			if notFirst[path] {
+				log.Printf("Info: File %s is no longer the first match of its pattern (--first-match).\n", path)
+			} else if _, err := a.source.Stat(path); err == nil {
+				log.Printf("Info: File %s no longer matches any pattern.\n", path)
+			}
 			a.handleFileRemoval(path)
 		}
 		return true
@@ -182,17 +1334,37 @@ func (a *app) setupWatchers() {
 	})
 }
 
+// errDirWatchLimit is recorded for directories not watched because --max-dir-watches was reached.
+var errDirWatchLimit = errors.New("--max-dir-watches reached")
+
 // addToWatchDir adds a directory to the dirWatcher. It returns true if the directory
 // was successfully added or was already being watched.
 func (a *app) addToWatchDir(realDir string) (added bool) {
+	// Without a watcher, new files are only found by the periodic scan.
+	if a.pollOnly {
+		return false
+	}
+
 	// Check if the directory is already being watched.
 	prevErr, loaded := a.watchedDirs.Load(realDir)
 	if loaded && prevErr == nil {
 		return true
 	}
 
-	err := a.dirWatcher.Add(realDir)
+	var err error
+	if a.maxDirWatches > 0 && a.dirWatches.Load() >= int64(a.maxDirWatches) {
+		// Treat the directory like one whose watch failed, so it is covered by scanning instead.
+		err = errDirWatchLimit
+		a.dirWatchLimit.Do(func() {
+			log.Printf("Warning: Reached --max-dir-watches %d, further directories are only scanned for new files.\n", a.maxDirWatches)
+		})
+	} else if err = a.dirWatcher.Add(realDir); err == nil {
+		a.dirWatches.Add(1)
+	}
 	a.watchedDirs.Store(realDir, err)
+	if err == errDirWatchLimit {
+		return false
+	}
 	if err != nil {
 		// A previous attempt to watch this directory failed.
 		// Try again, but don't print an error message this time.
@@ -200,54 +1372,201 @@ func (a *app) addToWatchDir(realDir string) (added bool) {
 			return false
 		}
 
-		log.Printf("Error: adding directory %s to watcher: %v\n", realDir, err)
+		a.logError("adding directory %s to watcher: %v\n", realDir, err)
 		return false
 	}
 
 	// This is the first attempt to watch this directory.
+	if a.startupWatches != nil {
+		a.startupWatches.dirs = append(a.startupWatches.dirs, realDir)
+		return true
+	}
 	log.Printf("Info: Watching directory: %s\n", realDir)
 	return true
 }
 
 // addToWatchFile adds a file to the watch list and sets its initial offset.
+// pattern is the glob pattern that matched the file, kept to make messages about the file traceable.
+// Files are read from the end, unless initial is true and --from-start is given,
+// or initial is false and --inclusive-initial is given for a file modified since startup,
+// or initial is true and --checkpoint is given for a file modified since the checkpoint.
+// In the latter case the whole file is new content, which could otherwise be missed
+// if it was written before the file was added.
 // It returns true if the file was added, false if it already exists or an error occurred.
-func (a *app) addToWatchFile(realPath string) (added bool) {
+func (a *app) addToWatchFile(realPath, pattern string, initial bool) (added bool) {
 	// Check if the file is already being watched.
 	if _, ok := a.watchedFiles.Load(realPath); ok {
+		// With --max-file-size, stop watching a file that grew past it. It is added again if it shrinks.
+		if a.maxFileSize > 0 {
+			if fi, err := a.source.Stat(realPath); err == nil && fi.Size() > int64(a.maxFileSize) {
+				log.Printf("Info: File %s grew past --max-file-size (%d bytes).\n", realPath, fi.Size())
+				a.handleFileRemoval(realPath)
+				return false
+			}
+		}
 		return true
 	}
 
 	// Get file information to determine the initial read offset.
-	fileInfo, err := os.Stat(realPath)
+	fileInfo, err := a.source.Stat(realPath)
 	if err != nil {
-		log.Printf("Error: getting file info for %s: %v\n", realPath, err)
+		if os.IsNotExist(err) {
+			// The file was removed again before it could be added.
+			log.Printf("Error: getting file info for %s (pattern %s): %v\n", realPath, pattern, err)
+		} else {
+			a.logError("getting file info for %s (pattern %s): %v\n", realPath, pattern, err)
+		}
 		return false
 	}
-
-	// Set the initial offset to the end of the file so we only tail new content.
-	offset := fileInfo.Size()
-	a.watchedFiles.Store(realPath, offset)
-	log.Printf("Info: Watching new file: %s\n", realPath)
-	return true
-}
-
-// handleFileRemoval removes a file from the watchedFiles map.
-func (a *app) handleFileRemoval(path string) {
+	if a.isOffsetFile(realPath) {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: "offset file of --offset-from", skipped: true})
+		return false
+	}
+	// Files found through events have not been checked by globWalk.
+	if reason := a.specialFileReason(fileInfo); reason != "" {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: reason, skipped: true})
+		return false
+	}
+	if a.maxFileSize > 0 && fileInfo.Size() > int64(a.maxFileSize) {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: "larger than --max-file-size", skipped: true})
+		return false
+	}
+	if fileInfo.Size() < int64(a.minFileSize) {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: "smaller than --min-file-size", skipped: true})
+		a.belowMinSize.Store(realPath, true)
+		return false
+	}
+	if a.tooYoung(realPath, initial) {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: "younger than --min-file-age", skipped: true})
+		return false
+	}
+	if a.textOnlySkip(realPath) {
+		a.logWalkNote(walkNote{path: realPath, pattern: pattern, reason: "does not look like text (--text-only)", skipped: true})
+		return false
+	}
+
+	// Set the initial offset to the end of the file so we only tail new content.
+	offset := fileInfo.Size()
+	if _, below := a.belowMinSize.LoadAndDelete(realPath); below {
+		offset = 0
+	} else if _, aged := a.agedFiles.LoadAndDelete(realPath); aged {
+		offset = 0
+	} else if initial && (a.fromStart || a.reverse && a.tailBytes == 0) {
+		offset = 0
+	} else if initial && a.tailBytes > 0 {
+		offset = a.tailBytesOffset(realPath, offset)
+	} else if !initial && a.inclusive && !fileInfo.ModTime().Before(a.startTime) {
+		offset = 0
+	} else if off, ok := a.stateOffset(realPath, fileInfo); ok {
+		offset = off
+	} else if off, ok := a.offsetFromFile(realPath, offset); ok {
+		offset = off
+	} else if initial && a.modifiedSinceCheckpoint(fileInfo) {
+		offset = 0
+	} else if a.sparseProbe {
+		// The reported size of a sparse file may lie past the data written so far.
+		if file, err := a.openFile(realPath); err == nil {
+			offset = a.dataSize(file, offset)
+			a.closeFile(file)
+		}
+	}
+	id, _ := a.source.Identity(realPath, fileInfo)
+	state := &fileState{offset: offset, pattern: pattern, id: id, lastActivity: time.Now()}
+	if a.markLive && offset < fileInfo.Size() {
+		state.backlogEnd = fileInfo.Size()
+	}
+	a.initDiff(realPath, state)
+	a.sniffTimestamp(realPath, state)
+	a.watchedFiles.Store(realPath, state)
+	if !initial {
+		a.noteDirEvent(dirEventCreated, realPath)
+	}
+	if a.startupWatches != nil {
+		a.startupWatches.files = append(a.startupWatches.files, realPath)
+		return true
+	}
+	log.Printf("Info: Watching new file: %s\n", realPath)
+	return true
+}
+
+// startupWatches are the files and directories watched by the first setupWatchers.
+type startupWatches struct {
+	files []string
+	dirs  []string
+}
+
+// logStartupWatches logs the files and directories watched at startup, as a single summary
+// if there are more files than --watch-summary. Files found later are logged one by one.
+func (a *app) logStartupWatches() {
+	w := a.startupWatches
+	a.startupWatches = nil
+	if len(w.files) > a.watchSummary {
+		dirs := make(map[string]bool)
+		for _, file := range w.files {
+			dirs[filepath.Dir(file)] = true
+		}
+		log.Printf("Info: Watching %d files across %d directories\n", len(w.files), len(dirs))
+		return
+	}
+	for _, dir := range w.dirs {
+		log.Printf("Info: Watching directory: %s\n", dir)
+	}
+	for _, file := range w.files {
+		log.Printf("Info: Watching new file: %s\n", file)
+	}
+}
+
+// handleFileRemoval removes a file from the watchedFiles map.
+func (a *app) handleFileRemoval(path string) {
 	_, ok := a.watchedFiles.LoadAndDelete(path)
 	if ok {
 		log.Printf("Info: Stopped watching file: %s\n", path)
+		a.retireIntegrity(path)
+		a.noteDirEvent(dirEventRemoved, path)
 	}
 }
 
 // handleDirRemoval removes a directory from the dirWatcher.
 func (a *app) handleDirRemoval(dir string) {
-	if err := a.dirWatcher.Remove(dir); err != nil {
-		log.Printf("Error: removing directory %s from watcher: %v\n", dir, err)
+	// Only directories whose watch succeeded are known to the watcher.
+	if prevErr, loaded := a.watchedDirs.LoadAndDelete(dir); loaded && prevErr == nil {
+		if err := a.dirWatcher.Remove(dir); err != nil {
+			log.Printf("Error: removing directory %s from watcher: %v\n", dir, err)
+		}
+		a.dirWatches.Add(-1)
 	}
-	a.watchedDirs.Delete(dir)
 	log.Printf("Info: Stopped watching directory: %s\n", dir)
 }
 
+// handleChmod re-checks the readability of a file whose attributes changed, so permission flips
+// are noticed without waiting for the next poll or scan. A matching file not watched yet is added.
+func (a *app) handleChmod(path string) {
+	value, ok := a.watchedFiles.Load(path)
+	if !ok {
+		if pattern, ok := a.globMatch(path); ok {
+			a.addToWatchFile(path, pattern, false)
+		}
+		return
+	}
+	state := value.(*fileState)
+
+	file, err := a.openFile(path)
+	if os.IsNotExist(err) {
+		// The removal is handled by its own event.
+		return
+	}
+	if err != nil {
+		if !state.unreadable.Swap(true) {
+			a.logError("file %s (pattern %s) is no longer readable: %v\n", path, state.pattern, err)
+		}
+		return
+	}
+	a.closeFile(file)
+	if state.unreadable.Swap(false) {
+		log.Printf("Info: File %s is readable again.\n", path)
+	}
+}
+
 // handleDirEvents processes events from the directory watcher.
 func (a *app) handleDirEvents() {
 	var event fsnotify.Event
@@ -260,11 +1579,25 @@ func (a *app) handleDirEvents() {
 			if !ok {
 				return
 			}
+			a.traceEvent(event)
+			// Only the operations of --watch-ops are acted on.
+			event.Op &= a.watchOpMask
 
 			// Handle new files created in a watched directory.
 			// It checks if the event name matches a glob pattern.
-			if event.Op&fsnotify.Create != 0 && a.globMatch(event.Name) {
-				a.addToWatchFile(event.Name)
+			if event.Op&fsnotify.Create != 0 {
+				if pattern, ok := a.globMatch(event.Name); !ok {
+					a.tracef("created %s matches no pattern\n", event.Name)
+				} else if a.createIgnored(event.Name, pattern) {
+					a.tracef("created %s is ignored (pattern %s)\n", event.Name, pattern)
+				} else if a.firstMatch {
+					// Whether it is the first match of its pattern is decided by the next scan.
+					a.tracef("created %s is left to the scan (--first-match)\n", event.Name)
+				} else if realPath, ok := a.createdRealPath(event.Name); !ok {
+					a.tracef("created %s is a symlink not resolved yet, left to the scan after the retries\n", event.Name)
+				} else {
+					a.addToWatchFile(realPath, pattern, false)
+				}
 			}
 
 			// Handle files removed or renamed from a watched directory.
@@ -272,109 +1605,636 @@ func (a *app) handleDirEvents() {
 				a.handleFileRemoval(event.Name)
 			}
 
+			// Handle permission changes, which may make a file readable or unreadable.
+			if event.Op&fsnotify.Chmod != 0 {
+				a.handleChmod(event.Name)
+			}
+
 		case err, ok = <-a.dirWatcher.Errors:
 			// If the error channel is closed, exit the goroutine.
 			if !ok {
 				return
 			}
 
-			log.Printf("Error: Directory watcher error: %v\n", err)
+			a.logError("Directory watcher error: %v\n", err)
 		}
 	}
 }
 
-// pollFiles periodically polls watched files for new content.
-func (a *app) pollFiles() {
+// pollFiles periodically polls watched files for new content until ctx is canceled
+// or, with --stop-at-eof, all files have been read to the end. It closes a.done when it returns.
+func (a *app) pollFiles(ctx context.Context) {
+	defer close(a.done)
+
 	// Create a new Ticker that fires at the specified pollInterval.
 	ticker := time.NewTicker(a.pollInterval)
 	// Stop the Ticker when this goroutine exits.
 	defer ticker.Stop()
 
+	// again is signaled to poll again right away, without waiting for the ticker.
+	again := make(chan struct{}, 1)
+
+	// The no-change message has a ticker of its own.
+	idle, stopIdle := a.newIdleTicker(a.dispInterval)
+	defer func() { stopIdle() }()
+
 	lastContentUpdate := time.Now()
+	lastOffsetsReport := time.Now()
+	a.reportProgress(lastContentUpdate)
+	lastCountsReport := time.Now()
 
 	// The loop waits for the Ticker to fire, ensuring a consistent interval.
-	prevPath := ""
-	for range ticker.C {
-		// Iterate through all currently watched files.
-		a.watchedFiles.Range(func(key, value interface{}) bool {
-			path := key.(string)
-			offset := value.(int64)
-			var err error
-
-			// Check if the file still exists on the filesystem.
-			var fileInfo os.FileInfo
-			fileInfo, err = os.Stat(path)
-			if os.IsNotExist(err) {
-				// If it doesn't exist, remove it from the watch list.
-				a.handleFileRemoval(path)
-				return true // Continue to the next iteration.
+	for {
+		select {
+		case reloaded := <-a.pollReload:
+			// Apply reloaded intervals. This goroutine owns them, so no locking is needed.
+			if reloaded.dispInterval != a.dispInterval {
+				a.dispInterval = reloaded.dispInterval
+				stopIdle()
+				idle, stopIdle = a.newIdleTicker(a.dispInterval)
 			}
-			if err != nil {
-				log.Printf("Error: getting file info for %s: %v\n", path, err)
-				return true
+			a.debugOffsets = reloaded.debugOffsets
+			a.adaptiveMax = reloaded.adaptiveMax
+			a.remountInterval = reloaded.scanInterval
+			if reloaded.pollInterval != a.pollInterval {
+				a.pollInterval = reloaded.pollInterval
+				ticker.Reset(a.pollInterval)
 			}
+			continue
+		case <-ctx.Done():
+			a.finishOutput()
+			return
+		case <-a.replay:
+			a.replayFiles()
+			continue
+		case now := <-idle:
+			a.reportIdle(lastContentUpdate, now)
+			continue
+		case <-ticker.C:
+		case <-again:
+		}
 
-			// Open the file to read its contents.
-			var file *os.File
-			file, err = os.Open(path)
-			if err != nil {
-				log.Printf("Error: opening file %s: %v\n", path, err)
-				return true
-			}
-			// Ensure the file is closed after returning from this function.
-			defer func() { _ = file.Close() }()
+		// While paused with --pause-mode hold, nothing is read, so the offsets stay put.
+		if a.holdPaused() {
+			continue
+		}
+
+		// With --batch-markers, the output of this cycle forms a batch.
+		a.beginBatch()
+
+		// Log messages since the last cycle go before the content read in this one.
+		a.writeLogRecords()
+		a.writeFooters()
+		a.writeDirEvents()
 
-			// Check if the file was truncated (current size is smaller than offset).
-			currentSize := fileInfo.Size()
-			if currentSize < offset {
-				log.Printf("Info: File %s truncated, re-reading from start.\n", path)
-				offset = 0 // Reset the offset to the beginning of the file.
+		// Poll the watched files, up to --read-workers of them concurrently.
+		// With --watch-dir-events, only the files coming and going are output, not their content.
+		cycle := &pollCycle{allDrained: true}
+		a.checkRemounts(time.Now())
+		if a.dirEvents == nil {
+			a.pollAll(cycle, time.Now())
+		}
+		a.enforceBufferMemory(time.Now())
+		a.saveStateDue(time.Now())
+		allDrained := cycle.allDrained
+		if !cycle.contentAt.IsZero() {
+			lastContentUpdate = cycle.contentAt
+		}
+		if cycle.more {
+			select {
+			case again <- struct{}{}:
+			default:
 			}
+		}
 
-			// Seek to the last read position.
-			_, err = file.Seek(offset, io.SeekStart)
-			if err != nil {
-				log.Printf("Error: seeking file %s: %v\n", path, err)
-				return true
+		if a.limiter != nil {
+			a.limiter.report(time.Now(), false)
+		}
+		a.renderStatus(time.Now())
+		a.reportProgress(time.Now())
+		if a.matches != nil {
+			a.matches.report(time.Now(), false)
+		}
+		if a.dedup != nil {
+			a.dedup.report(time.Now(), false)
+		}
+
+		// Write out the content buffered during this cycle.
+		a.flushGroups(false)
+		a.endBatch()
+		a.flushOutput()
+
+		// With --stop-at-eof, exit once every file has been drained and nothing has grown for the grace period.
+		if a.stopAtEOF && allDrained && time.Since(lastContentUpdate) >= a.eofGrace {
+			a.flushPartialLines()
+			a.finishOutput()
+			log.Print("Info: all files read to the end, exiting")
+			return
+		}
+
+		// Report the offsets on their own schedule, regardless of whether any content was read.
+		if a.debugOffsets && a.dispInterval > 0 && time.Since(lastOffsetsReport) > a.dispInterval {
+			a.logOffsets()
+			lastOffsetsReport = time.Now()
+		}
+
+		// With --count, emit the tally instead of the content.
+		if a.count && a.dispInterval > 0 && time.Since(lastCountsReport) > a.dispInterval {
+			a.emitCounts(time.Now())
+			lastCountsReport = time.Now()
+		}
+	}
+}
+
+// pollCycle is the outcome of polling all watched files once. With --read-workers,
+// files are polled concurrently, so mu guards the fields and the output.
+type pollCycle struct {
+	mu sync.Mutex
+	// allDrained stays true if every watched file has been read up to its current size.
+	allDrained bool
+	// contentAt is when new content was last found, or zero if none was.
+	contentAt time.Time
+	// more is true if a file was only read up to --progress chunk, so the next cycle follows right away.
+	more bool
+}
+
+// notDrained records that a file was not read up to its current size.
+func (c *pollCycle) notDrained() {
+	c.mu.Lock()
+	c.allDrained = false
+	c.mu.Unlock()
+}
+
+// pollAll polls every watched file once. With --read-workers greater than 1, the files are
+// handed to a pool of that many goroutines, so a file that is slow to read, e.g. on a laggy
+// network mount, does not hold up the others. Each file is polled by a single goroutine,
+// and the output is serialized through c.mu.
+func (a *app) pollAll(c *pollCycle, now time.Time) {
+	if a.readWorkers <= 1 {
+		a.watchedFiles.Range(func(key, value interface{}) bool {
+			a.pollFile(key.(string), value.(*fileState), now, c)
+			return true
+		})
+		return
+	}
+
+	type job struct {
+		path  string
+		state *fileState
+	}
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range a.readWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				a.pollFile(j.path, j.state, now, c)
 			}
+		}()
+	}
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		jobs <- job{key.(string), value.(*fileState)}
+		return true
+	})
+	close(jobs)
+	wg.Wait()
+}
+
+// pollFile reads the new content of a watched file, and emits it.
+//
+// The offset follows the file through these cases, checked in this order:
+//   - removed: the file is no longer watched; it is added again if it is recreated and still matches.
+//   - replaced: the identity of the file changed (see fileID), e.g. a rotation renamed a new file
+//     over it, or with --checksum-verify its first bytes changed. The file is read from the start.
+//   - shrunk: the file is smaller than the offset, whether truncated to zero or only partially.
+//     The file is read from the start.
+//   - truncated during the read: the size after the read is smaller than the offset reached.
+//     The file is read from the start in the next poll, without waiting for the stat to notice it.
+//   - grown: the bytes from the offset to the end are read, possibly fewer with --sparse-probe,
+//     --max-bytes-per-sec, or a device. The offset advances by the bytes read, including any
+//     that were stripped or dropped.
+//
+// Reading from the start also resets the line state of the file, see resetLines.
+func (a *app) pollFile(path string, state *fileState, now time.Time, c *pollCycle) {
+	offset := state.offset
+	var err error
+
+	// With --poll-interval adaptive, idle files are polled less often.
+	if !a.pollDue(state, now) {
+		return
+	}
+	hadContent := false
+	defer func() { a.schedulePoll(state, hadContent, now) }()
+
+	// Check if the file still exists on the filesystem.
+	var fileInfo os.FileInfo
+	fileInfo, err = a.source.Stat(path)
+	if os.IsNotExist(err) {
+		// If it doesn't exist, remove it from the watch list.
+		a.handleFileRemoval(path)
+		return
+	}
+	if errors.Is(err, errSourceUnavailable) {
+		// The file is polled again once the source can be reached, from its offset.
+		c.notDrained()
+		return
+	}
+	if err != nil {
+		a.logError("getting file info for %s (pattern %s): %v\n", path, state.pattern, err)
+		c.notDrained()
+		return
+	}
 
-			// Read all new data from the current position to the end of the file.
-			var newData []byte
-			newData, err = io.ReadAll(file)
+	// Open the file to read its contents.
+	var file sourceFile
+	file, err = a.openFile(path)
+	if os.IsNotExist(err) {
+		// The file was removed after the stat above; the next poll removes it.
+		c.notDrained()
+		return
+	}
+	if errors.Is(err, errSourceUnavailable) {
+		c.notDrained()
+		return
+	}
+	if err != nil {
+		if !state.unreadable.Swap(true) {
+			a.logError("opening file %s (pattern %s): %v\n", path, state.pattern, err)
+		}
+		c.notDrained()
+		return
+	}
+	if state.unreadable.Swap(false) {
+		log.Printf("Info: File %s is readable again.\n", path)
+	}
+	// Ensure the file is closed after returning from this function.
+	defer a.closeFile(file)
+
+	// Devices are only read with --read-devices, and have no offset to follow.
+	if isDeviceFile(fileInfo) {
+		if data := a.readDevice(path, state, file); len(data) > 0 {
+			c.mu.Lock()
+			a.emitContent(path, state, data)
+			c.contentAt = time.Now()
+			c.mu.Unlock()
+			state.lastActivity = time.Now()
+			hadContent = true
+		}
+		return
+	}
+
+	// Check if the file was replaced by another one under the same path, e.g. by a rotation
+	// renaming a new file over it. The offset of the old file is meaningless for the new one.
+	currentSize := fileInfo.Size()
+	if id, ok := a.source.Identity(path, fileInfo); ok && id != state.id {
+		if state.id != "" {
+			log.Printf("Info: File %s replaced, re-reading from start.\n", path)
+			a.retireIntegrity(path)
+			offset = 0
+			a.resetLines(state)
+			a.diffReplaced(path, state)
+		}
+		state.id = id
+	}
+
+	// With --checksum-verify, a changed start of the file also means it was replaced,
+	// which catches replacements the identity check cannot, e.g. reinitialized files.
+	if a.checksumVerify && headerChanged(file, state) && offset > 0 {
+		log.Printf("Info: File %s content replaced, re-reading from start.\n", path)
+		offset = 0
+		a.resetLines(state)
+		a.diffReplaced(path, state)
+	}
+
+	// Check if the file was truncated (current size is smaller than offset).
+	if currentSize < offset {
+		log.Printf("Info: File %s truncated, re-reading from start.\n", path)
+		offset = 0 // Reset the offset to the beginning of the file.
+		a.resetLines(state)
+		a.diffReplaced(path, state)
+	}
+
+	// With --text-only, sniff the file again whenever it is read from the start,
+	// as a truncated or replaced file may no longer be text. The scan adds it back if it becomes text.
+	if a.textOnly && offset == 0 && currentSize > 0 {
+		if text, known, err := a.sniffText(file); err == nil && known && !text {
+			log.Printf("Info: File %s does not look like text anymore (--text-only).\n", path)
+			a.handleFileRemoval(path)
+			return
+		}
+	}
+
+	// With --sparse-probe, the read stops at the end of the written data.
+	// This moves the file position, so it must be done before seeking.
+	readEnd := a.dataSize(file, currentSize)
+	offset = a.checkGap(path, state, offset, readEnd)
+
+	// With --reverse, what there is to read when the file is first polled is output newest line
+	// first. Whatever is appended later, or replaces the file, is read forward as usual.
+	if a.reverse && !state.reversed {
+		state.reversed = true
+		if readEnd > offset {
+			c.mu.Lock()
+			err = a.emitReversed(path, state, file, offset, readEnd)
+			c.contentAt = time.Now()
+			c.mu.Unlock()
 			if err != nil {
-				log.Printf("Error: reading file %s: %v\n", path, err)
-				return true
+				a.logError("reading file %s backward (pattern %s): %v\n", path, state.pattern, err)
 			}
+			offset = readEnd
+			hadContent = true
+		}
+	}
 
-			if len(newData) <= 0 {
-				return true
-			}
+	// Seek to the last read position.
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		a.logError("seeking file %s (pattern %s): %v\n", path, state.pattern, err)
+		c.notDrained()
+		return
+	}
 
-			// Print the path of the file before printing its new content.
-			// This helps to distinguish which file the log output is from.
-			if prevPath != path {
-				_, _ = fmt.Fprintln(os.Stdout)
-				_, _ = fmt.Fprintf(os.Stdout, "--- %s ---\n", path)
-				prevPath = path
+	// Read all new data from the current position to the end of the file.
+	var reader io.Reader = file
+	if a.sparseProbe {
+		reader = io.LimitReader(file, readEnd-offset)
+	}
+	// With --on-limit block, only read what the rate allows. The rest stays in the file,
+	// and is read in later polls.
+	if a.limiter != nil && a.onLimit == limitBlock {
+		c.mu.Lock()
+		budget := a.limiter.available(time.Now())
+		c.mu.Unlock()
+		if budget <= 0 {
+			c.notDrained()
+			return
+		}
+		reader = io.LimitReader(reader, budget)
+	}
+	// With --progress, read large files in chunks, so their progress can be reported in between.
+	if a.progressLog != nil {
+		reader = io.LimitReader(reader, progressChunkSize)
+	}
+	var newData []byte
+	newData, err = io.ReadAll(reader)
+	if err != nil {
+		a.logError("reading file %s (pattern %s): %v\n", path, state.pattern, err)
+		c.notDrained()
+		return
+	}
+
+	// The file may have been truncated or grown since it was stat'ed above.
+	// Trust the size after the read over the one before it.
+	if fi, err := file.Stat(); err == nil {
+		currentSize = fi.Size()
+	}
+
+	if len(newData) > 0 {
+		// The offset still counts the stripped and dropped bytes, so the next read starts after them.
+		data := newData
+		// The output, and what it is limited by, is shared with the other files read concurrently.
+		c.mu.Lock()
+		// skipped counts the bytes dropped before those of data, for the offset of its first line.
+		skipped := 0
+		if a.limiter != nil {
+			if a.onLimit == limitDrop {
+				data, skipped = a.limiter.admit(data, a.delim, &state.lines.limitTail, time.Now())
+			} else {
+				a.limiter.take(len(data))
 			}
+		}
+		if a.stripBOM && offset == 0 && skipped == 0 {
+			trimmed := bytes.TrimPrefix(data, utf8BOM)
+			skipped, data = len(data)-len(trimmed), trimmed
+		}
+		state.lines.readAt = offset + int64(skipped)
+		// With --diff-mode, only the lines a new version of the file adds are emitted.
+		data = a.diffData(state, data)
+		if len(data) > 0 {
+			a.emitContent(path, state, data)
+		}
+		offset += int64(len(newData))
+		if state.backlogEnd > 0 && offset >= state.backlogEnd {
+			a.writeLiveMarker(path)
+			state.backlogEnd = 0
+		}
+		c.contentAt = time.Now() // Update the timestamp when new content is found.
+		c.mu.Unlock()
+	}
 
-			_, _ = fmt.Fprint(os.Stdout, string(newData))
-			offset += int64(len(newData))
-			a.watchedFiles.Store(path, offset) // Store the new offset.
+	// If the file is now shorter than what was read, it was truncated during the read.
+	// Start over right away, instead of waiting for the next stat to notice it,
+	// by which time the writer may have already written past the stale offset.
+	if currentSize < offset {
+		log.Printf("Info: File %s truncated while reading, re-reading from start.\n", path)
+		offset = 0
+		a.resetLines(state)
+		a.diffReplaced(path, state)
+	}
+	state.offset = offset // Store the new offset.
+	if offset < currentSize {
+		c.notDrained()
+		if a.progressLog != nil && len(newData) == progressChunkSize {
+			c.mu.Lock()
+			c.more = true
+			c.mu.Unlock()
+		}
+	}
+	if len(newData) > 0 {
+		state.lastActivity = time.Now()
+		hadContent = true
+	}
+}
+
+// emitContent emits new content of a file, line by line if lineMode is on.
+func (a *app) emitContent(path string, state *fileState, data []byte) {
+	if a.isJSONArray(state) {
+		a.emitJSONArray(path, state, data)
+		return
+	}
+	if a.lineMode() {
+		a.emitLines(path, state, data)
+	} else {
+		a.emit(path, data)
+	}
+}
 
-			lastContentUpdate = time.Now() // Update the timestamp when new content is found.
+// finishOutput writes the final output before pollFiles returns.
+func (a *app) finishOutput() {
+	a.writeLogRecords()
+	a.writeDirEvents()
+	if a.integrity != nil {
+		a.integrity.retireAll()
+		a.writeFooters()
+	}
+	a.flushGroups(true)
+	if a.limiter != nil {
+		a.limiter.report(time.Now(), true)
+	}
+	if a.matches != nil {
+		a.matches.report(time.Now(), true)
+	}
+	if a.dedup != nil {
+		a.dedup.report(time.Now(), true)
+	}
+	if a.count {
+		a.emitCounts(time.Now())
+	}
+	a.flushOutput()
+	if a.split != nil {
+		a.split.closeAll()
+	}
+	if a.printOffsets {
+		a.logFinalOffsets()
+	}
+	if a.state != nil {
+		a.saveState(time.Now())
+	}
+}
+
+// logFinalOffsets logs the offset of each watched file, sorted by path, for --print-offsets-on-exit.
+// Each offset is where the output stopped, before any partial last line not output yet, so content
+// from there on has not been output.
+func (a *app) logFinalOffsets() {
+	offsets := make(map[string]int64)
+	var paths []string
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		path := key.(string)
+		paths = append(paths, path)
+		offsets[path] = value.(*fileState).outputOffset()
+		return true
+	})
+	sort.Strings(paths)
+	for _, path := range paths {
+		log.Printf("Info: Final offset %d of %s\n", offsets[path], path)
+	}
+}
+
+// logOffsets logs the stored read offset and the current on-disk size of each watched file.
+// It is a diagnostic aid for files that seem not to be followed, e.g. when the offset is stuck
+// or ahead of the file after a rotation or truncation.
+func (a *app) logOffsets() {
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		path := key.(string)
+		offset := value.(*fileState).offset
+
+		fileInfo, err := a.source.Stat(path)
+		if err != nil {
+			log.Printf("Debug: %s offset=%d size=unknown (%v)\n", path, offset, err)
 			return true
-		})
+		}
+
+		size := fileInfo.Size()
+		switch {
+		case offset > size:
+			log.Printf("Debug: %s offset=%d size=%d (offset ahead of file)\n", path, offset, size)
+		case offset < size:
+			log.Printf("Debug: %s offset=%d size=%d (%d bytes pending)\n", path, offset, size, size-offset)
+		default:
+			log.Printf("Debug: %s offset=%d size=%d\n", path, offset, size)
+		}
+		return true
+	})
+}
+
+// quietestFiles describes up to n watched files that have been silent the longest, with their idle times,
+// e.g. "/var/log/a.log (1h2m0s), /var/log/b.log (3m10s)". It returns "" if n is 0 or no file is watched.
+func (a *app) quietestFiles(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	type quiet struct {
+		path string
+		idle time.Duration
+	}
+	var files []quiet
+	now := time.Now()
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		files = append(files, quiet{key.(string), now.Sub(value.(*fileState).lastActivity)})
+		return true
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].idle > files[j].idle })
+	if len(files) > n {
+		files = files[:n]
+	}
 
-		// If no new content was read during this poll cycle and the time since the last
-		// content update is longer than dispInterval, print a message.
-		if a.dispInterval > 0 && time.Since(lastContentUpdate) > a.dispInterval {
-			log.Print("Info: no files changed")
-			lastContentUpdate = time.Now()
+	s := make([]string, 0, len(files))
+	for _, f := range files {
+		s = append(s, fmt.Sprintf("%s (%v)", f.path, f.idle.Round(time.Second)))
+	}
+	return strings.Join(s, ", ")
+}
+
+// emit writes new content of a file to the output. Its lines reach the --serve clients through
+// outputLine instead, see publishLine.
+func (a *app) emit(path string, data []byte) {
+	// The --emit-offsets spans of data are consumed by the formatter, or dropped with it.
+	defer func() { a.spans = a.spans[:0] }()
+	if a.dropPaused(len(data)) {
+		return
+	}
+	shown := a.displayPath(path)
+	if a.integrity != nil {
+		a.integrity.add(path, data, a.delim)
+	}
+	if a.status != nil {
+		a.status.record(shown, bytes.Count(data, a.delim), len(data))
+	}
+
+	// Frames carry the path of the file themselves.
+	if a.framing == framingLength {
+		a.writeFrames(shown, data)
+		return
+	}
+
+	if a.split != nil {
+		a.writeSplit(path, shown, data)
+	} else if a.groupByFile {
+		a.groups.add(shown, a.emittedFileID(path), data)
+		if a.groups.size >= maxGroupBytes {
+			a.flushGroups(true)
 		}
+	} else {
+		a.writeContent(shown, a.emittedFileID(path), data)
 	}
 }
 
+// writeContent writes content of a file to the output, under a header naming the file.
+// path is the path as shown in the output, see displayPath, and id the identity of the file
+// with --emit-file-id, or empty. With --format other than raw, the lines are written through
+// the formatter instead.
+func (a *app) writeContent(path, id string, data []byte) {
+	// Lines rendered with --output-template are written as they are, without headers.
+	if a.lineTemplate != nil {
+		a.writeOutput(data)
+		return
+	}
+	if a.formatter != nil {
+		a.writeFormatted(path, id, data)
+		return
+	}
+	// With --print0, records are only told apart by their terminator; there are no headers.
+	if a.print0 {
+		a.writeOutput(bytes.ReplaceAll(a.prefixSeq(data), a.delim, []byte{0}))
+		return
+	}
+
+	// Print the path of the file before printing its new content.
+	// This helps to distinguish which file the log output is from.
+	// With --emit-file-id, a file replacing another under the same path gets a header of its own.
+	header := path
+	if id != "" {
+		header += " (" + id + ")"
+	}
+	if a.prevPath != header && a.split == nil {
+		a.writeOutput([]byte("\n--- " + header + " ---\n"))
+		a.prevPath = header
+	}
+
+	a.writeOutput(a.prefixSeq(data))
+}
+
 // scanForNewFiles periodically scans for new files matching the glob patterns.
 // This is a fallback in case fsnotify events are missed.
 func (a *app) scanForNewFiles() {
@@ -383,23 +2243,266 @@ func (a *app) scanForNewFiles() {
 	// Stop the Ticker when this goroutine exits.
 	defer ticker.Stop()
 
+	// retryC fires at --retry-interval to retry the directories whose watch failed.
+	// It is nil, and never fires, if retrying is disabled or there is no watcher.
+	var retryC <-chan time.Time
+	if a.retryInterval > 0 && !a.pollOnly {
+		retry := time.NewTicker(a.retryInterval)
+		defer retry.Stop()
+		retryC = retry.C
+	}
+
+	// static are the glob patterns given, and manifest the --manifest file adding to them.
+	static, manifest := a.args.patterns, a.manifest
+
 	// The loop waits for the Ticker to fire, ensuring a consistent interval.
-	for range ticker.C {
-		a.setupWatchers()
+	for {
+		select {
+		case <-retryC:
+			a.scanFailedDirs()
+		case <-a.symlinkRescan:
+			a.setupWatchers(false)
+		case reloaded := <-a.scanReload:
+			// Swap the glob patterns before rescanning, so files that no longer match are removed
+			// and files that still match keep their offsets.
+			static, manifest = reloaded.patterns, reloaded.manifest
+			a.manifestEntries = nil
+			a.setPatterns(static)
+			a.refreshManifest(manifest, static)
+			if reloaded.scanInterval != a.scanInterval {
+				a.scanInterval = reloaded.scanInterval
+				ticker.Reset(a.scanInterval)
+			}
+			a.setupWatchers(false)
+			log.Printf("Info: Reloaded configuration with %d glob patterns\n", len(reloaded.patterns))
+		case <-ticker.C:
+			// Files added to or removed from the manifest are picked up by the scan,
+			// as are directories given as patterns that were created since the last one.
+			if !a.refreshManifest(manifest, static) {
+				a.refreshDirectoryPatterns()
+			}
+			a.setupWatchers(false)
+		}
+	}
+}
+
+// scanFailedDirs retries adding the directories whose watch failed (e.g. because of the inotify
+// limit or permissions) to the watcher. Files created in such directories get no fast CREATE events,
+// so until the watch succeeds, the directories are also scanned here for new matching files,
+// at a faster cadence than the full scan.
+func (a *app) scanFailedDirs() {
+	var failed []string
+	a.watchedDirs.Range(func(key, value interface{}) bool {
+		if err, _ := value.(error); err != nil {
+			failed = append(failed, key.(string))
+		}
+		return true
+	})
+
+	for _, dir := range failed {
+		if a.addToWatchDir(dir) {
+			continue
+		}
+
+		entries, err := fs.ReadDir(a.discovery.DirFS(dir), ".")
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, ok := a.watchedFiles.Load(path); ok {
+				continue
+			}
+			if pattern, ok := a.globMatch(path); ok {
+				a.addToWatchFile(path, pattern, false)
+			}
+		}
+	}
+}
+
+// handleReloadSignals re-parses the stored command-line arguments and the config file
+// each time a signal is received, and hands the result to the goroutines owning the affected state.
+func (a *app) handleReloadSignals(sigs <-chan os.Signal) {
+	for range sigs {
+		reloaded, err := parseArgs(a.cmdName, a.cmdArgs)
+		if err == nil {
+			err = reloaded.validate()
+		}
+		if err != nil {
+			log.Printf("Error: reloading configuration: %v\n", err)
+			continue
+		}
+		if len(reloaded.patterns) < 1 && reloaded.manifest == "" {
+			log.Printf("Error: reloading configuration: no glob patterns given, keeping the current ones\n")
+			continue
+		}
+		if reloaded.remote != a.remote {
+			log.Printf("Error: reloading configuration: the patterns moved to another host, which only takes effect on a restart; keeping the current ones\n")
+			continue
+		}
+
+		a.warnNotReloaded(reloaded)
+		a.pollReload <- reloaded
+		a.scanReload <- reloaded
+	}
+}
+
+// reloadableFlags are the flags whose changes a reload applies; changes of all others only take
+// effect on a restart.
+var reloadableFlags = map[string]bool{
+	"poll-interval": true,
+	"scan-interval": true,
+	"disp-interval": true,
+	"debug-offsets": true,
+	"manifest":      true,
+}
+
+// warnNotReloaded logs a warning for each flag whose value differs in the reloaded configuration
+// but which a reload does not apply, so the change is not silently ignored.
+func (a *app) warnNotReloaded(reloaded *args) {
+	var changed []string
+	for name, value := range reloaded.flagValues {
+		if !reloadableFlags[name] && a.flagValues[name] != value {
+			changed = append(changed, "--"+name)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		log.Printf("Warning: reloading configuration: %s changed but only take effect on a restart\n", strings.Join(changed, ", "))
+	}
+}
+
+// setPatterns compiles the given glob patterns and makes them the current ones.
+// Patterns given more than once are only kept once, as walking them again would find nothing new.
+func (a *app) setPatterns(patterns []string) {
+	compiled := make([]globPattern, 0, len(patterns))
+	byBase := make(map[string][]int)
+	seen := make(map[string]bool, len(patterns))
+	a.givenPatterns = patterns
+	a.patternDirs = make(map[string]bool)
+	for _, p := range patterns {
+		if !a.regex {
+			if expanded := a.directoryPattern(p); expanded != p {
+				a.patternDirs[p] = true
+				p = expanded
+			}
+		}
+		if seen[p] {
+			log.Printf("Warning: Ignoring duplicate glob pattern %s\n", p)
+			continue
+		}
+		seen[p] = true
+
+		var g globPattern
+		if a.regex {
+			g = compileRegexPattern(p, a.root, a.discovery)
+		} else {
+			g = compilePattern(p, a.discovery)
+		}
+		byBase[g.absBase] = append(byBase[g.absBase], len(compiled))
+		if g.realBase != g.absBase {
+			byBase[g.realBase] = append(byBase[g.realBase], len(compiled))
+		}
+		compiled = append(compiled, g)
+	}
+	a.patternsMu.Lock()
+	a.globPatterns = compiled
+	a.patternsByBase = byBase
+	a.patternsMu.Unlock()
+}
+
+// directoryPattern expands a pattern naming an existing directory to the glob of the files in it,
+// DIR/*, or with --recursive of those in its subdirectories as well, DIR/**. New files in it are
+// then found like for any other pattern. The directory is taken literally, so one named e.g.
+// logs[1] has its metacharacters escaped. Other patterns are returned as they are.
+func (a *app) directoryPattern(p string) string {
+	if !a.isDir(p) {
+		return p
 	}
+	glob := "*"
+	if a.recursive {
+		glob = "**"
+	}
+	expanded := filepath.Join(literalPattern(p), glob)
+	if _, logged := a.reportedNotes.LoadOrStore("directory pattern\x00"+p, true); !logged {
+		log.Printf("Info: %s is a directory, following %s\n", p, expanded)
+	}
+	return expanded
+}
+
+// isDir reports whether path names an existing directory of the source.
+func (a *app) isDir(path string) bool {
+	fi, err := a.source.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// refreshDirectoryPatterns compiles the patterns again if one of them came to name a directory
+// since they were compiled, or stopped to, so a directory created after startup is followed
+// like one that existed then. It is called on every scan.
+func (a *app) refreshDirectoryPatterns() {
+	if a.regex {
+		return
+	}
+	for _, p := range a.givenPatterns {
+		if a.isDir(p) != a.patternDirs[p] {
+			a.setPatterns(a.givenPatterns)
+			return
+		}
+	}
+}
+
+// patterns returns the current compiled glob patterns.
+func (a *app) patterns() []globPattern {
+	a.patternsMu.RLock()
+	defer a.patternsMu.RUnlock()
+	return a.globPatterns
+}
+
+// walkNote describes a decision globWalk made about a candidate path other than plainly watching it.
+type walkNote struct {
+	// path is the candidate path as matched by the pattern.
+	path string
+	// pattern is the glob pattern that matched it.
+	pattern string
+	// reason explains the decision.
+	reason string
+	// skipped is true if the path was not passed to the action.
+	skipped bool
 }
 
 // globWalk performs a walk of the filesystem based on glob patterns.
-// It resolves symbolic links and calls a provided action function for each matching file.
-func (a *app) globWalk(action func(realPath string) error) error {
+// It resolves symbolic links and calls a provided action function for each matching file,
+// together with the glob pattern (as given by the user) that matched it first.
+// Decisions about candidates that were skipped, or watched in an unusual way, are passed to note
+// if it is not nil.
+//
+// If action returns errStopWalk, the walk of all patterns ends there without an error.
+// Any other error of action is a genuine one: it ends the walk of that pattern only, and is
+// returned together with those of other patterns.
+func (a *app) globWalk(action func(realPath, pattern string) error, note func(walkNote)) error {
+	if note == nil {
+		note = func(walkNote) {}
+	}
 	// A local map to keep track of processed files to avoid duplicate actions.
 	files := make(map[string]bool)
-	for _, p := range a.globPatterns {
-		// Split the glob pattern into the base directory and the rest of the pattern.
-		base, pattern := doublestar.SplitPattern(p)
-		fs := os.DirFS(base)
+	// A failing pattern does not stop the walk of the others; their errors are joined.
+	var errs []error
+	for _, p := range a.patterns() {
+		// The glob pattern was split into the base directory and the rest of the pattern by setPatterns.
+		base, pattern := p.base, p.pattern
+		fs := newLoopSafeFS(a.discovery, base, func(name string) {
+			note(walkNote{path: filepath.Join(p.absBase, filepath.FromSlash(name)), pattern: p.raw, reason: reasonSymlinkLoop, skipped: true})
+		})
+		// Remember the device of the base directory to notice matches on other filesystems.
+		baseDev, baseDevOK := uint64(0), false
+		if fi, err := a.source.Stat(p.realBase); err == nil {
+			baseDev, baseDevOK = deviceOf(fi)
+		}
 		// Use doublestar.GlobWalk to match bash-like globs with a callback.
-		err := doublestar.GlobWalk(fs, pattern, func(path string, d os.DirEntry) (err error) {
+		visit := func(path string, d os.DirEntry) (err error) {
 			resolvedPath := filepath.Join(base, path)
 
 			absolutePath, err := filepath.Abs(resolvedPath)
@@ -408,43 +2511,254 @@ func (a *app) globWalk(action func(realPath string) error) error {
 			}
 
 			// Resolve symlinks and get the real path.
-			realPath, err := filepath.EvalSymlinks(absolutePath)
+			realPath, err := a.evalSymlinks(absolutePath)
+			if errors.Is(err, errSymlinkRetrying) {
+				// Its target may appear in a moment; the scan after the retries finds it or reports the link.
+				return nil
+			}
 			if err != nil {
+				reason := symlinkErrorReason(err)
+				if os.IsNotExist(err) || reason == reasonSymlinkLoop {
+					// A dangling or looping symlink cannot be read, so there is nothing to watch.
+					note(walkNote{path: absolutePath, pattern: p.raw, reason: reason, skipped: true})
+					return nil
+				}
+				// Fall back to the unresolved path. The same file may then be watched twice
+				// if it is also reachable through a path that does resolve.
+				if !os.IsPermission(err) {
+					reason = fmt.Sprintf("%s after %d retries", reason, symlinkRetries)
+				}
+				note(walkNote{path: absolutePath, pattern: p.raw, reason: reason + ", watching the unresolved path"})
 				realPath = absolutePath
 			}
 
 			// If the file has already been processed, return.
 			if files[realPath] {
+				if realPath != absolutePath {
+					note(walkNote{path: absolutePath, pattern: p.raw, reason: "same file as " + realPath, skipped: true})
+				}
 				return nil
 			}
 
+			// Directories cannot be tailed.
+			fileInfo, err := a.source.Stat(realPath)
+			if err == nil && fileInfo.IsDir() {
+				note(walkNote{path: absolutePath, pattern: p.raw, reason: "directory", skipped: true})
+				return nil
+			}
+			if err != nil && os.IsPermission(err) {
+				note(walkNote{path: absolutePath, pattern: p.raw, reason: "permission denied", skipped: true})
+				return nil
+			}
+			if a.isOffsetFile(realPath) {
+				note(walkNote{path: absolutePath, pattern: p.raw, reason: "offset file of --offset-from", skipped: true})
+				return nil
+			}
+			if by := a.ignoredBy(absolutePath, p); by != "" {
+				note(walkNote{path: absolutePath, pattern: p.raw, reason: "excluded by " + by, skipped: true})
+				return nil
+			}
+			if err == nil {
+				if reason := a.specialFileReason(fileInfo); reason != "" {
+					note(walkNote{path: absolutePath, pattern: p.raw, reason: reason, skipped: true})
+					return nil
+				}
+			}
+			if err == nil && baseDevOK {
+				if dev, ok := deviceOf(fileInfo); ok && dev != baseDev {
+					note(walkNote{path: absolutePath, pattern: p.raw, reason: "on another filesystem than " + p.realBase})
+				}
+			}
+
 			// Perform the specified action on the file.
-			err = action(realPath)
-			if err != nil {
+			err = action(realPath, p.raw)
+			if errors.Is(err, errStopWalk) {
 				return err
 			}
+			if err != nil {
+				return fmt.Errorf("processing %s: %w", realPath, err)
+			}
 
 			// Mark the file as processed.
 			files[realPath] = true
 			return nil
-		})
+		}
+		var err error
+		if p.re != nil {
+			err = walkRegex(fs, p, visit)
+		} else {
+			err = doublestar.GlobWalk(fs, pattern, visit)
+		}
+		if err == nil {
+			err = fs.unavailable
+		}
+		if errors.Is(err, errStopWalk) {
+			break
+		}
 		if err != nil {
-			err = fmt.Errorf("glob pattern %s error: %w", p, err)
-			return err
+			errs = append(errs, fmt.Errorf("glob pattern %s error: %w", p.raw, err))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// errStopWalk is returned by a globWalk action to end the walk early, e.g. once it found what it looks for.
+// It is not an error, so globWalk does not return or log it.
+var errStopWalk = errors.New("stop walk")
+
+// reasonSymlinkLoop is the walk note reason for symlinks that resolve to themselves.
+const reasonSymlinkLoop = "symlink loop"
+
+// symlinkRetries is how often resolving a symlink is retried after it failed, and symlinkRetryDelay
+// the wait before the first retry, doubled for each further one. A rotation can leave a symlink
+// dangling for a moment, between removing the old target and creating the new one.
+const (
+	symlinkRetries    = 3
+	symlinkRetryDelay = 10 * time.Millisecond
+)
+
+// errSymlinkRetrying is wrapped by the error of evalSymlinks for a symlink whose resolving is retried.
+var errSymlinkRetrying = errors.New("resolving symlinks is retried")
+
+// evalSymlinks resolves the symlinks of a path found by globWalk or a directory event. An error
+// that may be transient starts retrying in the background, and until the retries end, the error
+// wraps errSymlinkRetrying, so the caller skips the path without waiting; the scan after the retries
+// takes it up again. Loops and missing permissions are not retried, nor symlinks whose retries ran
+// out, so a link that stays dangling is reported once and does not start retries on every scan.
+// With --list, there is no later scan, so nothing is retried.
+func (a *app) evalSymlinks(path string) (string, error) {
+	realPath, err := a.discovery.EvalSymlinks(path)
+	if err == nil {
+		a.symlinkRetries.Delete(path)
+		return realPath, nil
+	}
+	if a.list || symlinkErrorReason(err) == reasonSymlinkLoop || os.IsPermission(err) {
+		return realPath, err
+	}
+	if retrying, started := a.symlinkRetries.LoadOrStore(path, true); !started {
+		a.retrySymlink(path, 1, symlinkRetryDelay)
+	} else if !retrying.(bool) {
+		return realPath, err
+	}
+	return realPath, fmt.Errorf("%w: %w", errSymlinkRetrying, err)
 }
 
-// globMatch checks if a given realPath matches any of the glob patterns.
-func (a *app) globMatch(realPath string) bool {
-	// Use a custom error to signal a match without continuing the walk.
-	found := errors.New("glob is match")
-	err := a.globWalk(func(path string) error {
-		if path == realPath {
-			return found
+// retrySymlink resolves the symlinks of path again after delay, doubling it for each further retry,
+// until it resolves or the retries run out. Either way, it then asks for a scan.
+func (a *app) retrySymlink(path string, retry int, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		_, err := a.discovery.EvalSymlinks(path)
+		if err != nil && retry < symlinkRetries {
+			a.retrySymlink(path, retry+1, delay*2)
+			return
+		}
+		if err == nil {
+			a.symlinkRetries.Delete(path)
+		} else {
+			a.symlinkRetries.Store(path, false)
 		}
+		select {
+		case a.symlinkRescan <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// symlinkErrorReason describes why filepath.EvalSymlinks failed.
+func symlinkErrorReason(err error) string {
+	switch {
+	case os.IsNotExist(err):
+		return "dangling symlink"
+	case os.IsPermission(err):
+		return "permission denied resolving symlinks"
+	case strings.Contains(err.Error(), "too many links"):
+		return reasonSymlinkLoop
+	default:
+		return fmt.Sprintf("cannot resolve symlinks: %v", err)
+	}
+}
+
+// logWalkNote logs a walk decision the first time it is made for a path,
+// so the periodic scan does not repeat it. Skipped directories are not logged,
+// as broad patterns commonly match them.
+func (a *app) logWalkNote(n walkNote) {
+	if n.reason == "directory" {
+		return
+	}
+	if _, loaded := a.reportedNotes.LoadOrStore(n.path+"\x00"+n.reason, true); loaded {
+		return
+	}
+	if n.skipped {
+		log.Printf("Info: Skipping %s (pattern %s): %s\n", n.path, n.pattern, n.reason)
+	} else {
+		log.Printf("Info: Watching %s (pattern %s): %s\n", n.path, n.pattern, n.reason)
+	}
+}
+
+// listMatches prints the files the glob patterns currently match, and the candidates
+// that were skipped or are watched in an unusual way, together with the reasons.
+func (a *app) listMatches() error {
+	return a.globWalk(func(realPath, pattern string) error {
+		_, _ = fmt.Fprintf(os.Stdout, "watch\t%s\t(pattern %s)\n", realPath, pattern)
 		return nil
+	}, func(n walkNote) {
+		kind := "note"
+		if n.skipped {
+			kind = "skip"
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "%s\t%s\t%s (pattern %s)\n", kind, n.path, n.reason, n.pattern)
 	})
-	return errors.Is(err, found)
+}
+
+// globMatch checks if a given realPath matches any of the glob patterns, and returns the first one that does.
+// Instead of walking the filesystem, it matches the path against each precompiled pattern.
+// If realPath is a symbolic link, its resolved path is checked as well.
+func (a *app) globMatch(realPath string) (pattern string, ok bool) {
+	absolutePath, err := filepath.Abs(realPath)
+	if err != nil {
+		absolutePath = realPath
+	}
+	candidates := []string{absolutePath}
+	if resolved, err := a.discovery.EvalSymlinks(absolutePath); err == nil && resolved != absolutePath {
+		candidates = append(candidates, resolved)
+	}
+
+	a.patternsMu.RLock()
+	patterns, byBase := a.globPatterns, a.patternsByBase
+	a.patternsMu.RUnlock()
+
+	// Only the patterns based in an ancestor directory of a candidate can match it.
+	// Of those, the first one given wins, as in globWalk.
+	best := -1
+	for _, c := range candidates {
+		for dir := filepath.Dir(c); ; dir = filepath.Dir(dir) {
+			for _, i := range byBase[dir] {
+				if (best < 0 || i < best) && patterns[i].match(c) {
+					best = i
+				}
+			}
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return patterns[best].raw, true
+}
+
+// createdRealPath returns the real path of a file created in a watched directory, so a symlink
+// to a file already watched, e.g. found through a pattern with another base directory, is not watched
+// a second time. A path that cannot be resolved is used as is. ok is false for a symlink whose
+// resolving is being retried, which is left to the scan after the retries.
+func (a *app) createdRealPath(path string) (realPath string, ok bool) {
+	realPath, err := a.evalSymlinks(path)
+	if errors.Is(err, errSymlinkRetrying) {
+		return "", false
+	}
+	if err != nil {
+		return path, true
+	}
+	return realPath, true
 }
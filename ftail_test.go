@@ -0,0 +1,763 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// localApp returns an app with the args cfg reading the local filesystem, without the rest of the
+// setup of newApp, for testing methods that need no more.
+func localApp(cfg *args) *app {
+	return &app{args: cfg, source: localSource{}, discovery: localSource{}}
+}
+
+func TestDirectoryPattern(t *testing.T) {
+	dir := t.TempDir()
+	logs := filepath.Join(dir, "logs[1]")
+	if err := os.Mkdir(logs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(logs, "app.log")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		pattern   string
+		recursive bool
+		want      string
+	}{
+		{"directory", logs, false, filepath.Join(literalPattern(logs), "*")},
+		{"recursive", logs, true, filepath.Join(literalPattern(logs), "**")},
+		{"file", file, false, file},
+		{"glob", filepath.Join(dir, "*.log"), false, filepath.Join(dir, "*.log")},
+		{"missing", filepath.Join(dir, "missing"), false, filepath.Join(dir, "missing")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := localApp(&args{recursive: tt.recursive})
+			if got := a.directoryPattern(tt.pattern); got != tt.want {
+				t.Errorf("directoryPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+
+	// The expanded pattern matches the files of the directory, not those of logs1.
+	a := localApp(&args{})
+	a.setPatterns([]string{logs})
+	if _, ok := a.globMatch(file); !ok {
+		t.Errorf("%s does not match the pattern of its directory", file)
+	}
+	if _, ok := a.globMatch(filepath.Join(dir, "logs1", "app.log")); ok {
+		t.Errorf("the pattern of %s matches a file of logs1", logs)
+	}
+}
+
+func TestRefreshDirectoryPatterns(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "later")
+	file := filepath.Join(dir, "app.log")
+	a := localApp(&args{})
+	a.setPatterns([]string{dir})
+	if _, ok := a.globMatch(file); ok {
+		t.Fatalf("%s matches before its directory exists", file)
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	a.refreshDirectoryPatterns()
+	if _, ok := a.globMatch(file); !ok {
+		t.Errorf("%s does not match once its directory was created", file)
+	}
+}
+
+// benchmarkGlobMatch measures matching the paths of filesystem events against precompiled
+// patterns, as done for every event and scanned file. The paths are taken in turn.
+// The indexed lookup of globMatch is compared with matching the path, and its real path, against
+// every pattern in turn, as before the patterns were indexed by base directory; both must find the
+// same matches.
+func benchmarkGlobMatch(b *testing.B, patterns []string, paths []string) {
+	a := localApp(&args{})
+	a.setPatterns(patterns)
+	linear := func(path string) (string, bool) {
+		candidates := []string{path}
+		if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != path {
+			candidates = append(candidates, resolved)
+		}
+		for _, p := range patterns {
+			for _, c := range candidates {
+				if ok, _ := doublestar.Match(filepath.ToSlash(p), filepath.ToSlash(c)); ok {
+					return p, true
+				}
+			}
+		}
+		return "", false
+	}
+	for _, path := range paths {
+		p, ok := a.globMatch(path)
+		if wantP, wantOK := linear(path); p != wantP || ok != wantOK {
+			b.Fatalf("globMatch(%q) = %q, %v; matching every pattern gives %q, %v", path, p, ok, wantP, wantOK)
+		}
+	}
+
+	for _, bm := range []struct {
+		name  string
+		match func(path string) (string, bool)
+	}{
+		{"indexed", a.globMatch},
+		{"linear", linear},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = bm.match(paths[i%len(paths)])
+			}
+		})
+	}
+}
+
+func BenchmarkGlobMatch(b *testing.B) {
+	dir := b.TempDir()
+	patterns := []string{
+		filepath.Join(dir, "*.log"),
+		filepath.Join(dir, "**", "access-*.log"),
+		filepath.Join(dir, "app", "{error,debug}.log"),
+	}
+	paths := []string{
+		filepath.Join(dir, "server.log"),
+		filepath.Join(dir, "nginx", "access-1.log"),
+		filepath.Join(dir, "server.txt"),
+		filepath.Join(dir, "app", "trace.log"),
+	}
+	benchmarkGlobMatch(b, patterns, paths)
+}
+
+// BenchmarkGlobMatch500Patterns matches paths against 500 patterns with a directory each, as when
+// following the logs of many services. The patterns are indexed by base directory, so the cost of
+// the indexed lookup should stay close to that of a few patterns, while the linear one grows with them.
+func BenchmarkGlobMatch500Patterns(b *testing.B) {
+	dir := b.TempDir()
+	patterns := make([]string, 500)
+	for i := range patterns {
+		patterns[i] = filepath.Join(dir, fmt.Sprintf("service%d", i), "*.log")
+	}
+	paths := []string{
+		filepath.Join(dir, "service0", "app.log"),
+		filepath.Join(dir, "service499", "app.log"),
+		filepath.Join(dir, "service250", "app.txt"),
+		filepath.Join(dir, "other", "app.log"),
+	}
+	benchmarkGlobMatch(b, patterns, paths)
+}
+
+// testTail drives the read logic of ftail deterministically: the watched files are set up once,
+// as at startup, and each call of poll reads them like one cycle of pollFiles. There is no
+// directory watcher, as with --poll-only, so new files are only found by scan.
+type testTail struct {
+	t   *testing.T
+	a   *app
+	out bytes.Buffer
+	// logs collects the log messages.
+	logs bytes.Buffer
+}
+
+// newTestTail parses the command-line arguments given, and sets up the watched files as at startup.
+// The output has no file headers, so tests only see the content.
+func newTestTail(t *testing.T, arguments ...string) *testTail {
+	t.Helper()
+	return newSourceTail(t, nil, arguments...)
+}
+
+// testSource is a source of files for newSourceTail.
+type testSource interface {
+	fileSource
+	discovery
+}
+
+// newSourceTail is newTestTail finding and reading the files through src instead of the local
+// filesystem, unless src is nil.
+func newSourceTail(t *testing.T, src testSource, arguments ...string) *testTail {
+	t.Helper()
+	cfg, err := parseArgs("ftail", append([]string{"--poll-only"}, arguments...))
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := &testTail{t: t}
+	log.SetOutput(&tt.logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tt.a = newApp(cfg, func() {})
+	if err := tt.a.openState(); err != nil {
+		t.Fatal(err)
+	}
+	if src != nil {
+		// The patterns were compiled, and the ignore files looked for, on the local filesystem.
+		tt.a.source, tt.a.discovery = src, src
+		tt.a.setPatterns(cfg.patterns)
+		if tt.a.ignores != nil {
+			tt.a.ignores = newIgnoreCache(tt.a.ignoreFile, src)
+		}
+	}
+	tt.a.out = newOutput(&tt.out)
+	tt.a.setupWatchers(true)
+	return tt
+}
+
+// poll reads the watched files once, and returns the content output.
+func (tt *testTail) poll() string {
+	tt.cycle()
+	return tt.content()
+}
+
+// cycle reads the watched files once, leaving the output in out.
+func (tt *testTail) cycle() {
+	a := tt.a
+	a.beginBatch()
+	a.pollAll(&pollCycle{allDrained: true}, time.Now())
+	a.flushGroups(false)
+	a.endBatch()
+	a.flushOutput()
+}
+
+// scan looks for new matching files, like the periodic scan does.
+func (tt *testTail) scan() {
+	tt.a.setupWatchers(false)
+}
+
+// finish flushes the partial lines and the final output as on exit, and returns the content output.
+func (tt *testTail) finish() string {
+	tt.a.flushPartialLines()
+	tt.a.finishOutput()
+	return tt.content()
+}
+
+// content returns the content output since the last call, without file headers.
+func (tt *testTail) content() string {
+	defer tt.out.Reset()
+	return fileHeader.ReplaceAllString(tt.out.String(), "")
+}
+
+// fileHeader matches the file headers of the raw output.
+var fileHeader = regexp.MustCompile(`\n--- [^\n]* ---\n`)
+
+// writeFile writes data to the file at path, appending to it unless truncate is true.
+func writeFile(t *testing.T, path, data string, truncate bool) {
+	t.Helper()
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if truncate {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTruncationAndRotation(t *testing.T) {
+	type step struct {
+		// do changes the file at path.
+		do   func(t *testing.T, path string)
+		want string
+	}
+	appendData := func(data string) func(*testing.T, string) {
+		return func(t *testing.T, path string) { writeFile(t, path, data, false) }
+	}
+	truncate := func(size int64) func(*testing.T, string) {
+		return func(t *testing.T, path string) {
+			if err := os.Truncate(path, size); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	then := func(fs ...func(*testing.T, string)) func(*testing.T, string) {
+		return func(t *testing.T, path string) {
+			for _, f := range fs {
+				f(t, path)
+			}
+		}
+	}
+	rotate := func(t *testing.T, path string) {
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copyTruncate := func(t *testing.T, path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path+".1", string(data), true)
+		truncate(0)(t, path)
+	}
+
+	tests := []struct {
+		name string
+		// args are given before the pattern.
+		args []string
+		// initial is the content of the file at startup, which is not output.
+		initial string
+		steps   []step
+	}{
+		{
+			name:    "grow",
+			initial: "old\n",
+			steps: []step{
+				{appendData("one\n"), "one\n"},
+				{appendData("two\nthree\n"), "two\nthree\n"},
+				{func(*testing.T, string) {}, ""},
+			},
+		},
+		{
+			name:    "truncate to zero",
+			initial: "old\n",
+			steps: []step{
+				{appendData("one\n"), "one\n"},
+				{then(truncate(0), appendData("new\n")), "new\n"},
+				{appendData("more\n"), "more\n"},
+			},
+		},
+		{
+			name:    "truncate to zero and stay empty",
+			initial: "old\n",
+			steps: []step{
+				{truncate(0), ""},
+				{appendData("new\n"), "new\n"},
+			},
+		},
+		{
+			name:    "shrink partially",
+			initial: "old\nolder\n",
+			steps: []step{
+				// Smaller than the offset, so the rest is read from the start.
+				{truncate(4), "old\n"},
+				{appendData("new\n"), "new\n"},
+			},
+		},
+		{
+			name:    "shrink then grow past the offset",
+			initial: "old\n",
+			steps: []step{
+				// The shrink is noticed before the file grows past its old size again.
+				{truncate(2), "ol"},
+				{appendData("d again\n"), "d again\n"},
+			},
+		},
+		{
+			name:    "rotate by rename and recreate",
+			initial: "old\n",
+			steps: []step{
+				{appendData("one\n"), "one\n"},
+				{then(rotate, appendData("new\n")), "new\n"},
+				{appendData("more\n"), "more\n"},
+			},
+		},
+		{
+			name:    "rotate to a larger file",
+			initial: "old\n",
+			steps: []step{
+				// The new file is larger than the offset, so only its identity tells it apart.
+				{then(rotate, appendData("a new file, larger than the old one\n")), "a new file, larger than the old one\n"},
+			},
+		},
+		{
+			name:    "truncate discards a partial line",
+			args:    []string{"--include", "."},
+			initial: "old\n",
+			steps: []step{
+				{appendData("part"), ""},
+				{then(truncate(0), appendData("new\n")), "new\n"},
+			},
+		},
+		{
+			name:    "copy and truncate",
+			initial: "old\n",
+			steps: []step{
+				{appendData("one\n"), "one\n"},
+				{copyTruncate, ""},
+				{appendData("new\n"), "new\n"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			writeFile(t, path, tc.initial, true)
+			tt := newTestTail(t, append(tc.args, filepath.Join(dir, "*.log"))...)
+			if got := tt.poll(); got != "" {
+				t.Fatalf("initial content output: %q", got)
+			}
+			for i, s := range tc.steps {
+				s.do(t, path)
+				if got := tt.poll(); got != s.want {
+					t.Errorf("step %d: output %q, want %q\nlogs:\n%s", i, got, s.want, tt.logs.String())
+				}
+			}
+		})
+	}
+}
+
+// tailAppended follows an empty file with the arguments given, appends data to it, and returns
+// what is output for it, including a partial last line flushed as on exit.
+func tailAppended(t *testing.T, data string, arguments ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.log"), "", true)
+	tt := newTestTail(t, append(arguments, filepath.Join(dir, "*.log"))...)
+	writeFile(t, filepath.Join(dir, "app.log"), data, false)
+	return tt.poll() + tt.finish()
+}
+
+func TestInclusiveInitial(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"read from the start", []string{"--inclusive-initial"}, "first\nsecond\n"},
+		{"read from the end", nil, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "old.log"), "old\n", true)
+			tt := newTestTail(t, append(tc.args, filepath.Join(dir, "*.log"))...)
+			// The file existing at startup is read from the end either way.
+			if got := tt.poll(); got != "" {
+				t.Errorf("file existing at startup: output %q", got)
+			}
+
+			writeFile(t, filepath.Join(dir, "new.log"), "first\nsecond\n", true)
+			tt.scan()
+			if got := tt.poll(); got != tc.want {
+				t.Errorf("created file: output %q, want %q", got, tc.want)
+			}
+			writeFile(t, filepath.Join(dir, "new.log"), "third\n", false)
+			if got, want := tt.poll(), "third\n"; got != want {
+				t.Errorf("appended: output %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// watched returns the paths of the watched files.
+func (tt *testTail) watched() map[string]bool {
+	paths := make(map[string]bool)
+	tt.a.watchedFiles.Range(func(key, _ interface{}) bool {
+		paths[key.(string)] = true
+		return true
+	})
+	return paths
+}
+
+func TestOverlappingPatternRemoval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "", true)
+	all, app := filepath.Join(dir, "*.log"), filepath.Join(dir, "app*")
+	tt := newTestTail(t, all, app)
+	writeFile(t, path, "one\n", false)
+	if got := tt.poll(); got != "one\n" {
+		t.Fatalf("output %q, want %q", got, "one\n")
+	}
+
+	// A file still matched by another pattern keeps being watched from its offset.
+	tt.a.setPatterns([]string{app})
+	tt.scan()
+	if !tt.watched()[path] {
+		t.Fatalf("%s removed while another pattern still matches it", path)
+	}
+	writeFile(t, path, "two\n", false)
+	if got := tt.poll(); got != "two\n" {
+		t.Errorf("output %q, want %q", got, "two\n")
+	}
+
+	// An incomplete scan removes nothing, even if the file matches none of the patterns walked.
+	tt.a.setPatterns([]string{filepath.Join(dir, "*.txt"), filepath.Join(dir, "[")})
+	tt.scan()
+	if !tt.watched()[path] {
+		t.Errorf("%s removed after an incomplete scan", path)
+	}
+	if !strings.Contains(tt.logs.String(), "Not removing unmatched files") {
+		t.Errorf("the scan with a malformed pattern was not incomplete; logs:\n%s", tt.logs.String())
+	}
+
+	// Once no pattern matches it, the file is removed.
+	tt.a.setPatterns([]string{filepath.Join(dir, "*.txt")})
+	tt.scan()
+	if tt.watched()[path] {
+		t.Errorf("%s still watched after no pattern matches it", path)
+	}
+}
+
+// hookSource is a localSource calling atEOF once a file opened from it has been read to its end,
+// to change the file in the window between the read and the stat after it.
+type hookSource struct {
+	localSource
+	atEOF func(path string)
+}
+
+// Open implements fileSource.
+func (s hookSource) Open(path string) (sourceFile, error) {
+	f, err := s.localSource.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &hookFile{sourceFile: f, atEOF: func() { s.atEOF(path) }}, nil
+}
+
+// hookFile is a file opened from a hookSource.
+type hookFile struct {
+	sourceFile
+	atEOF func()
+}
+
+// Read implements io.Reader.
+func (f *hookFile) Read(p []byte) (int, error) {
+	n, err := f.sourceFile.Read(p)
+	if err == io.EOF && f.atEOF != nil {
+		f.atEOF()
+		f.atEOF = nil
+	}
+	return n, err
+}
+
+func TestTruncationDuringRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "old\n", true)
+	tt := newTestTail(t, filepath.Join(dir, "*.log"))
+
+	truncate := false
+	tt.a.source = hookSource{atEOF: func(path string) {
+		if truncate {
+			truncate = false
+			if err := os.Truncate(path, 0); err != nil {
+				t.Error(err)
+			}
+		}
+	}}
+
+	// What was read before the truncation is output.
+	writeFile(t, path, "one\ntwo\n", false)
+	truncate = true
+	if got, want := tt.poll(), "one\ntwo\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+	if !strings.Contains(tt.logs.String(), "truncated while reading") {
+		t.Errorf("the truncation during the read was not noticed; logs:\n%s", tt.logs.String())
+	}
+
+	// The file grows past the offset reached before the next poll, so only the size after
+	// the read told that it was truncated. It is read from the start.
+	const line = "a new line, longer than the old content\n"
+	writeFile(t, path, line, false)
+	if got := tt.poll(); got != line {
+		t.Errorf("output %q, want %q", got, line)
+	}
+}
+
+func TestReadWorkersSlowFile(t *testing.T) {
+	dir := t.TempDir()
+	slow, fast := filepath.Join(dir, "slow.log"), filepath.Join(dir, "fast.log")
+	writeFile(t, slow, "", true)
+	writeFile(t, fast, "", true)
+	tt := newTestTail(t, "--read-workers", "2", filepath.Join(dir, "*.log"))
+
+	// The read of slow.log blocks until released; fast.log is to be read meanwhile.
+	release := make(chan struct{})
+	fastRead := make(chan struct{}, 1)
+	tt.a.source = hookSource{atEOF: func(path string) {
+		switch path {
+		case slow:
+			<-release
+		case fast:
+			fastRead <- struct{}{}
+		}
+	}}
+	writeFile(t, slow, "slow\n", false)
+	writeFile(t, fast, "fast\n", false)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tt.cycle()
+	}()
+	select {
+	case <-fastRead:
+	case <-time.After(5 * time.Second):
+		t.Error("fast.log not read while slow.log was blocked")
+	}
+	close(release)
+	<-done
+
+	if got := tt.content(); !strings.Contains(got, "slow\n") || !strings.Contains(got, "fast\n") {
+		t.Errorf("output %q, want the lines of both files", got)
+	}
+}
+
+func TestGlobWalkActionErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/1.log", "a/2.log", "b/1.log"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, path, "", true)
+	}
+	a := localApp(&args{})
+	a.setPatterns([]string{filepath.Join(dir, "a", "*.log"), filepath.Join(dir, "b", "*.log")})
+	errAction := errors.New("action failed")
+
+	tests := []struct {
+		name string
+		// fail is the error returned by the action for the first file.
+		fail    error
+		visited []string
+		wantErr bool
+	}{
+		// An action error ends the walk of its pattern only, and is returned.
+		{"action error", errAction, []string{"a/1.log", "b/1.log"}, true},
+		// errStopWalk ends the whole walk, and is no error.
+		{"stop walk", errStopWalk, []string{"a/1.log"}, false},
+		{"no error", nil, []string{"a/1.log", "a/2.log", "b/1.log"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []string
+			err := a.globWalk(func(realPath, _ string) error {
+				rel, _ := filepath.Rel(dir, realPath)
+				visited = append(visited, filepath.ToSlash(rel))
+				if len(visited) == 1 {
+					return tt.fail
+				}
+				return nil
+			}, nil)
+			if !reflect.DeepEqual(visited, tt.visited) {
+				t.Errorf("visited %v, want %v", visited, tt.visited)
+			}
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want one: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, tt.fail) {
+				t.Errorf("error %v does not wrap %v", err, tt.fail)
+			}
+		})
+	}
+}
+
+func TestEvalSymlinksTransient(t *testing.T) {
+	dir := t.TempDir()
+	target, link := filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// rescanned waits for the scan asked for once the retries ended, and runs it.
+	var tt *testTail
+	rescanned := func() {
+		t.Helper()
+		select {
+		case <-tt.a.symlinkRescan:
+			tt.scan()
+		case <-time.After(5 * time.Second):
+			t.Fatal("no scan asked for after the retries")
+		}
+	}
+
+	// The dangling link is skipped while it is retried, without waiting and without a report.
+	start := time.Now()
+	tt = newTestTail(t, filepath.Join(dir, "*.log"))
+	if d := time.Since(start); d >= symlinkRetryDelay {
+		t.Errorf("the walk took %v, as if it waited for the retries", d)
+	}
+	if strings.Contains(tt.logs.String(), "Skipping "+link) {
+		t.Errorf("%s reported while retrying; logs:\n%s", link, tt.logs.String())
+	}
+	// A directory event for it does not wait either, and the next scan does not start retries again.
+	start = time.Now()
+	if _, ok := tt.a.createdRealPath(link); ok {
+		t.Errorf("createdRealPath(%s) resolved while retrying", link)
+	}
+	tt.scan()
+	if d := time.Since(start); d >= symlinkRetryDelay {
+		t.Errorf("resolving %s again took %v, as if it waited", link, d)
+	}
+
+	// The target appears while resolving is retried, as after a rotation, and the scan after it watches it.
+	time.AfterFunc(symlinkRetryDelay+symlinkRetryDelay/2, func() {
+		if err := os.WriteFile(target, nil, 0o644); err != nil {
+			t.Error(err)
+		}
+	})
+	rescanned()
+	if !tt.watched()[target] {
+		t.Errorf("%s not watched through %s after the retries; logs:\n%s", target, link, tt.logs.String())
+	}
+
+	// A link that stays dangling is reported by the scan after the retries, and no longer retried.
+	dangling := filepath.Join(dir, "gone.log")
+	if err := os.Symlink(filepath.Join(dir, "missing"), dangling); err != nil {
+		t.Fatal(err)
+	}
+	tt.scan()
+	rescanned()
+	if n := strings.Count(tt.logs.String(), "Skipping "+dangling); n != 1 {
+		t.Errorf("dangling symlink %s reported %d times, want once; logs:\n%s", dangling, n, tt.logs.String())
+	}
+	if _, err := tt.a.evalSymlinks(dangling); !os.IsNotExist(err) {
+		t.Errorf("evalSymlinks(%s): error %v, want it not to exist", dangling, err)
+	}
+	tt.scan()
+	select {
+	case <-tt.a.symlinkRescan:
+		t.Errorf("%s retried again after the retries ran out", dangling)
+	case <-time.After(8 * symlinkRetryDelay):
+	}
+}
+
+func TestPrintOffsetsOnExit(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// want is the final offset after "old\n" was skipped and "a\nb" read.
+		want int64
+	}{
+		{"raw", nil, 7},
+		// The partial last line was not output, so it is read again after a restart.
+		{"lines", []string{"--line-buffered"}, 6},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			writeFile(t, path, "old\n", true)
+			tt := newTestTail(t, append(tc.args, "--print-offsets-on-exit", path)...)
+			writeFile(t, path, "a\nb", false)
+			tt.poll()
+			// Exiting on a signal does not flush the partial lines.
+			tt.a.finishOutput()
+			if want := fmt.Sprintf("Info: Final offset %d of %s\n", tc.want, path); !strings.Contains(tt.logs.String(), want) {
+				t.Errorf("%q not logged; logs:\n%s", want, tt.logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Actions of --on-gap.
+const (
+	gapWarn = "warn"
+	gapSkip = "skip"
+)
+
+// validGapAction checks an --on-gap.
+func validGapAction(action string) error {
+	if action != gapWarn && action != gapSkip {
+		return fmt.Errorf("--on-gap must be %s or %s, got %q", gapWarn, gapSkip, action)
+	}
+	return nil
+}
+
+// checkGap warns if more than --warn-on-gap bytes of a file are to be read at once, before reading
+// them, as an implausible growth may be corruption, a misconfigured writer, or a sparse file.
+// It returns the offset to read from: offset, or end with --on-gap skip, discarding any incomplete
+// line before the gap. A gap read in several polls, e.g. with --progress, is warned about once.
+func (a *app) checkGap(path string, state *fileState, offset, end int64) int64 {
+	if a.warnOnGap <= 0 || end-offset <= int64(a.warnOnGap) || offset < state.gapEnd {
+		return offset
+	}
+	state.gapEnd = end
+	if a.onGap == gapSkip {
+		log.Printf("Warning: %s has %s new to read at once, over --warn-on-gap; skipping it.\n", path, formatBytes(float64(end-offset)))
+		state.lines.partial = nil
+		return end
+	}
+	log.Printf("Warning: %s has %s new to read at once, over --warn-on-gap.\n", path, formatBytes(float64(end-offset)))
+	return offset
+}
@@ -1,10 +1,18 @@
-module github.com/ebe-rest/ftail
-
-go 1.24.4
-
-require (
-        github.com/bmatcuk/doublestar/v4 v4.9.1
-        github.com/fsnotify/fsnotify v1.9.0
-)
-
-require golang.org/x/sys v0.13.0 // indirect
+module github.com/ebe-rest/ftail
+
+go 1.24.4
+
+require (
+	github.com/bmatcuk/doublestar/v4 v4.9.1
+	github.com/fsnotify/fsnotify v1.9.0
+)
+
+require (
+	github.com/pkg/sftp v1.13.10
+	golang.org/x/sys v0.35.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+)
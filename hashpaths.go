@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// displayPath returns the path of a file as shown in the output. With --hash-paths, it is the first
+// 8 hex digits of the SHA-256 of the path, so output can be shared without revealing the filesystem
+// layout while lines of the same file can still be correlated. Each hash is logged once to standard
+// error together with its path, as a legend for the operator. With --merge-by, files are shown
+// by the name of their merged stream, see mergeKey. Otherwise files are shown by their real path,
+// whichever pattern and base directory they were found through, in the --path-style.
+func (a *app) displayPath(path string) string {
+	if key, ok := a.mergeKey(path); ok {
+		path = key
+	} else if !a.hashPaths {
+		return a.styledPath(path)
+	}
+	if !a.hashPaths {
+		return path
+	}
+	sum := sha256.Sum256([]byte(path))
+	hash := hex.EncodeToString(sum[:4])
+	if _, loaded := a.pathHashes.LoadOrStore(path, hash); !loaded {
+		log.Printf("Info: Path %s is shown as %s\n", path, hash)
+	}
+	return hash
+}
+
+// Values of --path-style.
+const (
+	pathAbsolute = "absolute"
+	pathRelative = "relative"
+)
+
+// styledPath returns the real path of a file in the --path-style: as is, or relative to the working
+// directory ftail was started in. Paths on another volume than it stay absolute.
+// Files of sftp:// patterns are shown by their URL.
+func (a *app) styledPath(path string) string {
+	if a.remote != (sftpTarget{}) {
+		return a.remote.url(path)
+	}
+	if a.pathStyle != pathRelative || a.workDir == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(a.workDir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// emittedFileID returns the identity of a watched file shown with --emit-file-id, see fileID,
+// or an empty string without it or if the identity is unknown.
+func (a *app) emittedFileID(path string) string {
+	if !a.emitFileID {
+		return ""
+	}
+	if v, ok := a.watchedFiles.Load(path); ok {
+		return v.(*fileState).id
+	}
+	return ""
+}
+
+// workDir returns the working directory, or "" if it cannot be told.
+func workDir() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathStyle(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"work", "logs", "other"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logs, other := filepath.Join(dir, "logs", "app.log"), filepath.Join(dir, "other", "app.log")
+	writeFile(t, logs, "", true)
+	writeFile(t, other, "", true)
+	// link is a symlinked directory; files found through it are shown by their real path.
+	if err := os.Symlink(filepath.Join(dir, "logs"), filepath.Join(dir, "work", "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	t.Chdir(filepath.Join(dir, "work"))
+
+	tests := []struct {
+		name     string
+		style    string
+		patterns []string
+		path     string
+		want     string
+	}{
+		{"absolute", pathAbsolute, []string{filepath.Join(dir, "logs", "*.log")}, logs, logs},
+		{"relative", pathRelative, []string{filepath.Join(dir, "logs", "*.log")}, logs, filepath.Join("..", "logs", "app.log")},
+		{"relative pattern", pathRelative, []string{filepath.Join("..", "other", "*.log")}, other, filepath.Join("..", "other", "app.log")},
+		{"through a symlink", pathRelative, []string{filepath.Join("link", "*.log")}, logs, filepath.Join("..", "logs", "app.log")},
+		{"absolute through a symlink", pathAbsolute, []string{filepath.Join("link", "*.log")}, logs, logs},
+		// A file matched by several patterns with other base directories is watched, and shown, once.
+		{"overlapping patterns", pathRelative, []string{filepath.Join(dir, "logs", "*.log"), filepath.Join(dir, "**", "*.log")}, logs, filepath.Join("..", "logs", "app.log")},
+		{"overlapping patterns through a symlink", pathAbsolute, []string{filepath.Join("link", "*.log"), filepath.Join("..", "logs", "*.log")}, logs, logs},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := newTestTail(t, append([]string{"--format", "text", "--path-style", tc.style}, tc.patterns...)...)
+			writeFile(t, tc.path, "line\n", false)
+			if got, want := tt.poll(), tc.want+": line\n"; got != want {
+				t.Errorf("output %q, want %q", got, want)
+			}
+			fi, err := os.Stat(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var same []string
+			for path := range tt.watched() {
+				if wfi, err := os.Stat(path); err == nil && os.SameFile(fi, wfi) {
+					same = append(same, path)
+				}
+			}
+			if len(same) != 1 {
+				t.Errorf("%s watched as %q, want once", tc.path, same)
+			}
+		})
+	}
+}
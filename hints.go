@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// flagHint returns a hint for a common mistake behind an error of parsing the command line, or "".
+func flagHint(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "flag needs an argument: "):
+		name := strings.TrimPrefix(msg, "flag needs an argument: ")
+		return fmt.Sprintf("%s takes a value; give it right after the flag, e.g. %s 500ms or %s=500ms", name, name, name)
+	case strings.HasPrefix(msg, "invalid value ") && strings.ContainsAny(msg, "/*"):
+		// A pattern probably took the place of a flag's value.
+		return "a glob pattern was given where a flag expects a value; put the value right after the flag, and the patterns after all flags"
+	default:
+		return ""
+	}
+}
+
+// patternHints returns hints for common mistakes in the glob patterns given on the command line.
+// shell is false if some of them come from a config file, which the shell does not expand.
+func patternHints(patterns []string, shell bool) []string {
+	var hints []string
+
+	// The flag package stops at the first pattern, so later flags are taken as patterns.
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "-") {
+			hints = append(hints, fmt.Sprintf("%q is taken as a glob pattern; flags must come before the patterns", p))
+		}
+	}
+
+	// An unquoted glob expanded by the shell shows up as several existing files of a directory.
+	// ftail then only follows those, and misses files created later.
+	if shell && len(patterns) > 1 {
+		dir, expanded := filepath.Dir(patterns[0]), true
+		for _, p := range patterns {
+			fi, err := os.Stat(p)
+			if err != nil || fi.IsDir() || filepath.Dir(p) != dir || strings.ContainsAny(p, "*?[{") {
+				expanded = false
+				break
+			}
+		}
+		if expanded {
+			hints = append(hints, fmt.Sprintf("the %d files of %s given may have been expanded by the shell from an unquoted glob; quote the pattern, e.g. '%s', to also follow files created later",
+				len(patterns), dir, filepath.Join(dir, "*"+filepath.Ext(patterns[0]))))
+		}
+	}
+	return hints
+}
+
+// validatePatterns returns an error naming the first glob pattern doublestar cannot parse.
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if !doublestar.ValidatePattern(filepath.ToSlash(p)) {
+			return fmt.Errorf("invalid glob pattern %q: %w; check for an unbalanced [ ] or { }", p, doublestar.ErrBadPattern)
+		}
+	}
+	return nil
+}
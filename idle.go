@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// idleTicker returns the channel of a ticker firing every --disp-interval for the no-change message,
+// and the function stopping it. The channel is nil, and never fires, if the message is disabled.
+// It is the newIdleTicker of the app, which tests replace to tick by hand.
+func idleTicker(d time.Duration) (ticks <-chan time.Time, stop func()) {
+	if d <= 0 {
+		return nil, func() {}
+	}
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// reportIdle logs the no-change message if no new content was read for at least --disp-interval,
+// with how long nothing changed. It is called every --disp-interval by its own ticker, so the
+// message keeps a steady cadence regardless of the poll interval and of sporadic content.
+func (a *app) reportIdle(lastContent, now time.Time) {
+	idle := now.Sub(lastContent)
+	// While held with --pause-mode hold, nothing is read, so the files are not idle.
+	if idle < a.dispInterval || a.holdPaused() {
+		return
+	}
+	idle = idle.Round(time.Second)
+	if quiet := a.quietestFiles(a.quietFiles); quiet != "" {
+		log.Printf("Info: no files changed for %v; quiet longest: %s", idle, quiet)
+	} else {
+		log.Printf("Info: no files changed for %v", idle)
+	}
+}
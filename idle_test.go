@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleTicker(t *testing.T) {
+	if ticks, stop := idleTicker(0); ticks != nil {
+		t.Error("ticker for a disabled message")
+	} else {
+		stop()
+	}
+	ticks, stop := idleTicker(time.Millisecond)
+	defer stop()
+	select {
+	case <-ticks:
+	case <-time.After(5 * time.Second):
+		t.Error("ticker did not fire")
+	}
+}
+
+func TestReportIdle(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		args   []string
+		idle   time.Duration
+		paused bool
+		want   string
+	}{
+		{"idle for the interval", []string{"--disp-interval", "10s"}, 30 * time.Second, false, "Info: no files changed for 30s; quiet longest: "},
+		{"not idle long enough", []string{"--disp-interval", "1m"}, 30 * time.Second, false, ""},
+		{"held", []string{"--disp-interval", "10s", "--pause-mode", "hold"}, 30 * time.Second, true, ""},
+		{"no quiet files named", []string{"--disp-interval", "10s", "--quiet-files", "0"}, time.Minute, false, "Info: no files changed for 1m0s\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "app.log"), "", true)
+			tt := newTestTail(t, append(tc.args, filepath.Join(dir, "*.log"))...)
+			tt.a.paused.Store(tc.paused)
+			tt.logs.Reset()
+			tt.a.reportIdle(now.Add(-tc.idle), now)
+			got := tt.logs.String()
+			if tc.want == "" && got != "" {
+				t.Errorf("logged %q, want nothing", got)
+			}
+			if tc.want != "" && !strings.Contains(got, tc.want) {
+				t.Errorf("logged %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestIdleMessageCadence checks that the no-change message follows the ticks of --disp-interval,
+// not the polls, which here happen many times between two ticks. The ticks are given by hand, as
+// if a minute passed between each.
+func TestIdleMessageCadence(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "app.log"), "", true)
+	tt := newTestTail(t, "--poll-interval", "1ms", "--scan-interval", "1h", "--disp-interval", "1m", filepath.Join(dir, "*.log"))
+	ticks := make(chan time.Time)
+	tt.a.newIdleTicker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	go tt.a.pollFiles(ctx)
+
+	const intervals = 5
+	for i := 1; i <= intervals; i++ {
+		// Each tick is taken once the loop is back waiting, after the polls in between.
+		time.Sleep(5 * time.Millisecond)
+		ticks <- start.Add(time.Duration(i)*time.Minute + time.Second)
+	}
+	cancel()
+	<-tt.a.done
+
+	if n := strings.Count(tt.logs.String(), "no files changed"); n < intervals-1 || n > intervals+1 {
+		t.Errorf("no-change message logged %d times in %d intervals; logs:\n%s", n, intervals, tt.logs.String())
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultIgnoreFile is the default --ignore-file name.
+const defaultIgnoreFile = ".ftailignore"
+
+// ignoreRule is a pattern of an ignore file, in the common subset of the gitignore syntax:
+// blank lines and # comments, ! to re-include, a trailing / to match directories only,
+// a leading or inner / to anchor the pattern to the directory of the ignore file, and the
+// wildcards *, ?, [...], and **. A pattern without a slash matches a name at any depth.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreFile parses the rules of an ignore file, skipping invalid patterns.
+func parseIgnoreFile(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.TrimSuffix(scanner.Text(), "\r"), " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		r.anchored = strings.Contains(line, "/")
+		r.pattern = strings.TrimPrefix(line, "/")
+		if r.pattern == "" || !doublestar.ValidatePattern(r.pattern) {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// match reports whether the rule matches a path relative to the directory of its ignore file,
+// in slash form.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	ok, _ := doublestar.Match(r.pattern, rel)
+	return ok
+}
+
+// ignoreCache holds the parsed --ignore-file of each directory looked at. It is cleared by
+// every scan, so changes to the ignore files take effect with the next scan.
+type ignoreCache struct {
+	// name is the name of the ignore files, e.g. .ftailignore.
+	name string
+	// discovery is where the ignore files are read from, that of the files they apply to.
+	discovery discovery
+	mu        sync.Mutex
+	// rules maps a directory to the rules of its ignore file, nil if it has none.
+	rules map[string][]ignoreRule
+}
+
+// newIgnoreCache creates a cache for the ignore files called name, read from d.
+func newIgnoreCache(name string, d discovery) *ignoreCache {
+	return &ignoreCache{name: name, discovery: d, rules: make(map[string][]ignoreRule)}
+}
+
+// reset forgets the ignore files read, so they are read again.
+func (c *ignoreCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.rules)
+}
+
+// rulesOf returns the rules of the ignore file in dir. An ignore file that cannot be read has none.
+func (c *ignoreCache) rulesOf(dir string) []ignoreRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rules, ok := c.rules[dir]
+	if !ok {
+		if data, err := fs.ReadFile(c.discovery.DirFS(dir), c.name); err == nil {
+			rules = parseIgnoreFile(data)
+		}
+		c.rules[dir] = rules
+	}
+	return rules
+}
+
+// ignoredBy returns the ignore file excluding a file below base, or "" if none does.
+// The ignore files of base and of the directories between it and the file apply, the deeper
+// ones taking precedence, as in git. A file in an excluded directory cannot be re-included.
+func (c *ignoreCache) ignoredBy(base, file string) string {
+	rel, err := filepath.Rel(base, file)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	// dirs[j] is the directory of the ignore file at depth j, base being at depth 0.
+	dirs := make([]string, len(parts))
+	dirs[0] = base
+	for j := 1; j < len(parts); j++ {
+		dirs[j] = filepath.Join(dirs[j-1], parts[j-1])
+	}
+	// Decide for each directory on the way to the file, then for the file.
+	for i := range parts {
+		isDir := i < len(parts)-1
+		by := ""
+		for j := 0; j <= i; j++ {
+			target := strings.Join(parts[j:i+1], "/")
+			for _, r := range c.rulesOf(dirs[j]) {
+				if r.match(target, isDir) {
+					by = ""
+					if !r.negate {
+						by = filepath.Join(dirs[j], c.name)
+					}
+				}
+			}
+		}
+		if by != "" {
+			return by
+		}
+	}
+	return ""
+}
+
+// ignoredBy returns the --ignore-file excluding a file matched by a pattern, or "" if none does
+// or ignore files are disabled. path is the file as found below the base directory of the pattern,
+// either its absolute or its real form.
+func (a *app) ignoredBy(path string, g globPattern) string {
+	if a.ignores == nil {
+		return ""
+	}
+	by := a.ignores.ignoredBy(g.absBase, path)
+	if by == "" && g.realBase != g.absBase {
+		by = a.ignores.ignoredBy(g.realBase, path)
+	}
+	return by
+}
+
+// patternByRaw returns the compiled pattern given as raw.
+func (a *app) patternByRaw(raw string) (globPattern, bool) {
+	a.patternsMu.RLock()
+	defer a.patternsMu.RUnlock()
+	for _, g := range a.globPatterns {
+		if g.raw == raw {
+			return g, true
+		}
+	}
+	return globPattern{}, false
+}
+
+// createIgnored reports whether a file created in a watched directory is excluded by an --ignore-file,
+// noting it if so.
+func (a *app) createIgnored(path, pattern string) bool {
+	g, ok := a.patternByRaw(pattern)
+	if !ok {
+		return false
+	}
+	by := a.ignoredBy(path, g)
+	if by != "" {
+		a.logWalkNote(walkNote{path: path, pattern: pattern, reason: "excluded by " + by, skipped: true})
+	}
+	return by != ""
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Checksums of --integrity-hash.
+const (
+	integrityCRC32  = "crc32"
+	integrityCRC64  = "crc64"
+	integritySHA256 = "sha256"
+)
+
+// newIntegrityHash returns a hash of the --integrity-hash algorithm.
+func newIntegrityHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case integrityCRC32:
+		return crc32.NewIEEE(), nil
+	case integrityCRC64:
+		return crc64.New(crc64.MakeTable(crc64.ECMA)), nil
+	case integritySHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("--integrity-hash must be %s, %s, or %s, got %q", integrityCRC32, integrityCRC64, integritySHA256, algorithm)
+}
+
+// integrityTally accumulates the content emitted for a file, for its --integrity-footer.
+type integrityTally struct {
+	bytes int64
+	lines int64
+	hash  hash.Hash
+}
+
+// retiredTally is the tally of a file that was rotated away or removed, waiting for its footer
+// to be written.
+type retiredTally struct {
+	path  string
+	tally *integrityTally
+}
+
+// integrityFooters tallies the content emitted per file, and writes a footer with the total bytes,
+// lines, and checksum of a file once it is rotated away, removed, or at shutdown, so a consumer
+// can verify it received all of it. Files are removed by the directory watcher, so the tallies
+// are guarded by mu and their footers written by the polling goroutine, see writeFooters.
+type integrityFooters struct {
+	algorithm string
+	mu        sync.Mutex
+	tallies   map[string]*integrityTally
+	retired   []retiredTally
+}
+
+// newIntegrityFooters creates the tallies of --integrity-footer, checksummed with algorithm.
+func newIntegrityFooters(algorithm string) *integrityFooters {
+	return &integrityFooters{algorithm: algorithm, tallies: make(map[string]*integrityTally)}
+}
+
+// add tallies content emitted for a file.
+func (f *integrityFooters) add(path string, data, delim []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.tallies[path]
+	if t == nil {
+		h, _ := newIntegrityHash(f.algorithm)
+		t = &integrityTally{hash: h}
+		f.tallies[path] = t
+	}
+	t.bytes += int64(len(data))
+	t.lines += int64(bytes.Count(data, delim))
+	_, _ = t.hash.Write(data)
+}
+
+// retire ends the tally of a file, so its footer is written next, and content emitted for the file
+// from then on, e.g. of the file that replaced it, starts a new one. Files without content emitted
+// get no footer.
+func (f *integrityFooters) retire(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if t := f.tallies[path]; t != nil {
+		f.retired = append(f.retired, retiredTally{path: path, tally: t})
+		delete(f.tallies, path)
+	}
+}
+
+// retireAll ends the tallies of all files, e.g. at shutdown.
+func (f *integrityFooters) retireAll() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	paths := make([]string, 0, len(f.tallies))
+	for path := range f.tallies {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		f.retired = append(f.retired, retiredTally{path: path, tally: f.tallies[path]})
+		delete(f.tallies, path)
+	}
+}
+
+// take returns the tallies retired since the last call.
+func (f *integrityFooters) take() []retiredTally {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	retired := f.retired
+	f.retired = nil
+	return retired
+}
+
+// integrityFooter is an --integrity-footer in --format json.
+type integrityFooter struct {
+	File      string    `json:"file"`
+	Marker    string    `json:"marker"`
+	Time      time.Time `json:"time"`
+	Bytes     int64     `json:"bytes"`
+	Lines     int64     `json:"lines"`
+	Algorithm string    `json:"algorithm"`
+	Checksum  string    `json:"checksum"`
+}
+
+// retireIntegrity ends the --integrity-footer tally of a file, if enabled.
+func (a *app) retireIntegrity(path string) {
+	if a.integrity != nil {
+		a.integrity.retire(path)
+	}
+}
+
+// writeFooters writes the --integrity-footer of the files retired since the last call.
+// It is called by the polling goroutine at the start of a poll cycle and before exiting.
+//   - json: an object {"file":"...","marker":"footer","time":"...","bytes":N,"lines":N,"algorithm":"crc32","checksum":"..."}.
+//   - logfmt: the same fields as a record with marker=footer.
+//   - raw and text: a line "--- path END bytes=N lines=N crc32=... ---".
+//
+// The checksum is of the content emitted for the file, before formatting, in hex.
+func (a *app) writeFooters() {
+	if a.integrity == nil {
+		return
+	}
+	for _, r := range a.integrity.take() {
+		shown := a.displayPath(r.path)
+		t := r.tally
+		sum := hex.EncodeToString(t.hash.Sum(nil))
+		var b []byte
+		switch a.format {
+		case "json":
+			b, _ = json.Marshal(integrityFooter{File: shown, Marker: "footer", Time: time.Now(), Bytes: t.bytes, Lines: t.lines, Algorithm: a.integrity.algorithm, Checksum: sum})
+			b = append(b, '\n')
+		case "logfmt":
+			var s bytes.Buffer
+			s.WriteString("time=" + time.Now().Format(time.RFC3339Nano) + " file=")
+			writeLogfmtValue(&s, shown)
+			s.WriteString(" marker=footer bytes=" + strconv.FormatInt(t.bytes, 10) + " lines=" + strconv.FormatInt(t.lines, 10))
+			s.WriteString(" algorithm=" + a.integrity.algorithm + " checksum=" + sum + "\n")
+			b = s.Bytes()
+		default:
+			b = []byte(fmt.Sprintf("--- %s END bytes=%d lines=%d %s=%s ---\n", shown, t.bytes, t.lines, a.integrity.algorithm, sum))
+		}
+		if a.print0 {
+			b[len(b)-1] = 0
+		}
+		if a.groupByFile {
+			a.groups.add(shown, a.emittedFileID(r.path), b)
+			continue
+		}
+		a.writeOutput(b)
+		// The next content of the file is headed again.
+		a.prevPath = ""
+	}
+}
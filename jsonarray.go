@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"strings"
+)
+
+// patternList is a repeatable flag naming glob patterns, as given with the patterns to follow.
+type patternList []string
+
+// String returns the patterns separated by commas.
+func (l *patternList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends a pattern.
+func (l *patternList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// isJSONArray reports whether the files of a pattern are followed as growing JSON arrays with --json-array.
+func (a *app) isJSONArray(state *fileState) bool {
+	return len(a.jsonArray) > 0 && slices.Contains(a.jsonArray, state.pattern)
+}
+
+// emitJSONArray parses new data of a file holding a growing JSON array, such as [{...}, {...}],
+// and emits each complete element as a line of compact JSON. An incomplete element is kept until
+// the rest of it has been read. The parser is tolerant of the states a file goes through while
+// being written: the brackets and commas between elements are skipped wherever they appear, so
+// a closing ] that a writer later overwrites to append more elements does no harm.
+func (a *app) emitJSONArray(path string, state *fileState, data []byte) {
+	ls := &state.lines
+	buf := append(ls.jsonPending, data...)
+	ls.jsonPending = nil
+
+	var out bytes.Buffer
+	for {
+		buf = bytes.TrimLeft(buf, " \t\r\n,[]")
+		if len(buf) == 0 {
+			break
+		}
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		var element json.RawMessage
+		err := dec.Decode(&element)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// The element is still being written.
+			ls.jsonPending = append([]byte(nil), buf...)
+			break
+		}
+		if err != nil {
+			log.Printf("Warning: invalid JSON array element in %s, skipping %d bytes: %v\n", path, len(buf), err)
+			break
+		}
+		if err := json.Compact(&out, element); err != nil {
+			out.Write(element)
+		}
+		out.WriteByte('\n')
+		buf = buf[dec.InputOffset():]
+	}
+
+	if out.Len() > 0 {
+		a.emitLines(path, state, out.Bytes())
+	}
+}
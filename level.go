@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultLevelRegex finds the severity token of a line for --min-level, as its first capture group.
+const defaultLevelRegex = `(?i)\b(trace|debug|info|notice|warn|warning|error|err|fatal|critical|crit)\b`
+
+// defaultLevels is the default --levels order, from least to most severe. Names separated by |
+// are synonyms of the same severity.
+const defaultLevels = "trace,debug,info|notice,warn|warning,error|err,fatal|critical|crit"
+
+// levelFilter selects the lines at or above the --min-level severity.
+type levelFilter struct {
+	re *regexp.Regexp
+	// ranks maps the lower-cased level names to their severity, starting at 1.
+	ranks map[string]int
+	// min is the severity of --min-level.
+	min int
+	// keepUnleveled tells whether lines without a level are output.
+	keepUnleveled bool
+}
+
+// newLevelFilter creates the filter of --min-level from the --levels order and the --level-regex.
+func newLevelFilter(minLevel, levels, expr string, keepUnleveled bool) (*levelFilter, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --level-regex: %w", err)
+	}
+	f := &levelFilter{re: re, ranks: make(map[string]int), keepUnleveled: keepUnleveled}
+	for i, names := range strings.Split(levels, ",") {
+		for _, name := range strings.Split(names, "|") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				return nil, fmt.Errorf("--levels has an empty level name in %q", levels)
+			}
+			if _, dup := f.ranks[name]; dup {
+				return nil, fmt.Errorf("--levels lists %q more than once", name)
+			}
+			f.ranks[name] = i + 1
+		}
+	}
+	f.min = f.ranks[strings.ToLower(minLevel)]
+	if f.min == 0 {
+		return nil, fmt.Errorf("--min-level %q is not one of the --levels", minLevel)
+	}
+	return f, nil
+}
+
+// level returns the severity of a line, without its newline, or 0 if it has no known level.
+func (f *levelFilter) level(line []byte) int {
+	m := f.re.FindSubmatch(line)
+	if m == nil {
+		return 0
+	}
+	token := m[0]
+	if len(m) > 1 {
+		token = m[1]
+	}
+	return f.ranks[string(bytes.ToLower(token))]
+}
+
+// levelKeep tells, with --min-level, whether a complete line, including its newline, is severe enough
+// to be output. A line without a level inherits the level of the last line of its file that had one,
+// so the continuation lines of a record, such as a stack trace, follow its first line.
+func (a *app) levelKeep(state *fileState, line []byte) bool {
+	if a.levels == nil {
+		return true
+	}
+	ls := &state.lines
+	if level := a.levels.level(a.lineContent(line)); level > 0 {
+		ls.level = level
+	}
+	if ls.level == 0 {
+		return a.levels.keepUnleveled
+	}
+	return ls.level >= a.levels.min
+}
+
+// validLevels checks the --min-level flags; the other level flags require --min-level.
+func (c *args) validLevels() error {
+	if c.minLevel == "" {
+		if c.levelRegex != defaultLevelRegex || c.levelOrder != defaultLevels || !c.keepUnleveled {
+			return errors.New("--level-regex, --levels, and --keep-unleveled require --min-level")
+		}
+		return nil
+	}
+	_, err := newLevelFilter(c.minLevel, c.levelOrder, c.levelRegex, c.keepUnleveled)
+	return err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Modes of --on-limit.
+const (
+	// limitBlock stops reading once --max-bytes-per-sec is reached, so nothing is lost
+	// and the files are read further once the rate allows it.
+	limitBlock = "block"
+	// limitDrop keeps reading, and drops the lines over --max-bytes-per-sec.
+	limitDrop = "drop"
+)
+
+// limitReportInterval is how often the bytes dropped by --on-limit drop are logged.
+const limitReportInterval = 10 * time.Second
+
+// throughputLimiter is a token bucket for --max-bytes-per-sec, shared by all files.
+// It holds at most a second worth of tokens. It is only accessed from the polling goroutine.
+type throughputLimiter struct {
+	rate   int64
+	tokens float64
+	last   time.Time
+	// dropped is the number of bytes dropped since lastReport, with --on-limit drop.
+	dropped    int64
+	lastReport time.Time
+}
+
+// newThroughputLimiter creates a limiter allowing rate bytes per second, starting with a full bucket.
+func newThroughputLimiter(rate int64) *throughputLimiter {
+	now := time.Now()
+	return &throughputLimiter{rate: rate, tokens: float64(rate), last: now, lastReport: now}
+}
+
+// available refills the bucket for the time passed, and returns the number of bytes that may be output.
+func (l *throughputLimiter) available(now time.Time) int64 {
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*float64(l.rate), float64(l.rate))
+	l.last = now
+	return int64(l.tokens)
+}
+
+// take consumes n bytes worth of tokens.
+func (l *throughputLimiter) take(n int) {
+	l.tokens -= float64(n)
+}
+
+// limitTail tells how the data of a file last passed to admit ended: after a complete line, or
+// in the middle of a line that was admitted or dropped.
+type limitTail int
+
+const (
+	tailComplete limitTail = iota
+	tailAdmitted
+	tailDropped
+)
+
+// admit returns the part of data that may be output with --on-limit drop, and counts the rest as dropped.
+// Lines are only dropped whole: the rest of a line the last data of the file ended in, as recorded in
+// tail, is admitted or dropped like its start, taking tokens regardless of the budget if admitted.
+// Then the following complete lines, ending with delim, are admitted as far as they fit, and an
+// incomplete last line only if all of data fits. skipped is the number of bytes dropped before the
+// part returned.
+func (l *throughputLimiter) admit(data, delim []byte, tail *limitTail, now time.Time) (kept []byte, skipped int) {
+	budget := l.available(now)
+	start := 0
+	if *tail != tailComplete {
+		i := bytes.Index(data, delim)
+		if i < 0 {
+			// The whole of data is the middle of the line.
+			if *tail == tailDropped {
+				l.dropped += int64(len(data))
+				return nil, len(data)
+			}
+			l.take(len(data))
+			return data, 0
+		}
+		start = i + len(delim)
+		if *tail == tailDropped {
+			l.dropped += int64(start)
+			skipped = start
+		} else {
+			budget -= int64(start)
+		}
+	}
+
+	end := len(data)
+	if rest := data[start:]; int64(len(rest)) > budget {
+		end = start
+		if budget > 0 {
+			if i := bytes.LastIndex(rest[:budget], delim); i >= 0 {
+				end = start + i + len(delim)
+			}
+		}
+	}
+	switch {
+	case bytes.HasSuffix(data, delim):
+		*tail = tailComplete
+	case end == len(data):
+		*tail = tailAdmitted
+	default:
+		*tail = tailDropped
+	}
+	l.take(end - skipped)
+	l.dropped += int64(len(data) - end)
+	return data[skipped:end], skipped
+}
+
+// report logs the bytes dropped since the last report, at most every limitReportInterval
+// unless force is true.
+func (l *throughputLimiter) report(now time.Time, force bool) {
+	if l.dropped == 0 || !force && now.Sub(l.lastReport) < limitReportInterval {
+		return
+	}
+	log.Printf("Warning: dropped %d bytes over --max-bytes-per-sec %d in the last %v\n", l.dropped, l.rate, now.Sub(l.lastReport).Round(100*time.Millisecond))
+	l.dropped, l.lastReport = 0, now
+}
+
+// validLimitMode reports an error if mode is not a known --on-limit mode.
+func validLimitMode(mode string) error {
+	if mode != limitBlock && mode != limitDrop {
+		return fmt.Errorf("--on-limit must be %q or %q, got %q", limitBlock, limitDrop, mode)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdmit(t *testing.T) {
+	tests := []struct {
+		name   string
+		budget int64
+		// reads are passed to admit one after the other, with no time passing in between.
+		reads []string
+		want  []string
+		// skipped are the bytes dropped before the admitted part of each read.
+		skipped []int
+		dropped int64
+	}{
+		{
+			name:    "all fits",
+			budget:  100,
+			reads:   []string{"one\ntwo\n"},
+			want:    []string{"one\ntwo\n"},
+			skipped: []int{0},
+		},
+		{
+			name:    "whole lines that fit",
+			budget:  10,
+			reads:   []string{"one\ntwo\nthree\n"},
+			want:    []string{"one\ntwo\n"},
+			skipped: []int{0},
+			dropped: 6,
+		},
+		{
+			name:    "no line fits",
+			budget:  3,
+			reads:   []string{"three\n"},
+			want:    []string{""},
+			skipped: []int{0},
+			dropped: 6,
+		},
+		{
+			name:   "rest of a dropped line is dropped",
+			budget: 6,
+			// The budget is used up, so the rest of "two" is dropped, and so is "three".
+			reads:   []string{"one\ntwo", "2\nthree\n"},
+			want:    []string{"one\n", ""},
+			skipped: []int{0, 2},
+			dropped: 3 + 8,
+		},
+		{
+			name:   "rest of an admitted line is admitted",
+			budget: 8,
+			// "two" fits whole in the first read, so its rest is admitted even over the budget.
+			reads:   []string{"one\ntwo", "2\nthree\n"},
+			want:    []string{"one\ntwo", "2\n"},
+			skipped: []int{0, 0},
+			dropped: 6,
+		},
+		{
+			name:    "middle of a dropped line",
+			budget:  4,
+			reads:   []string{"one\ntwo", "22", "2\nfour\n"},
+			want:    []string{"one\n", "", ""},
+			skipped: []int{0, 2, 2},
+			dropped: 3 + 2 + 7,
+		},
+		{
+			name:    "line over a delimiter of several bytes",
+			budget:  7,
+			reads:   []string{"one\r\ntwo\r\n"},
+			want:    []string{"one\r\n"},
+			skipped: []int{0},
+			dropped: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delim := []byte("\n")
+			if strings.Contains(tt.reads[0], "\r\n") {
+				delim = []byte("\r\n")
+			}
+			now := time.Now()
+			l := newThroughputLimiter(tt.budget)
+			l.last = now
+			var tail limitTail
+			for i, read := range tt.reads {
+				kept, skipped := l.admit([]byte(read), delim, &tail, now)
+				if string(kept) != tt.want[i] {
+					t.Errorf("read %d: admitted %q, want %q", i, kept, tt.want[i])
+				}
+				if skipped != tt.skipped[i] {
+					t.Errorf("read %d: skipped %d, want %d", i, skipped, tt.skipped[i])
+				}
+				if got := read[skipped:]; !strings.HasPrefix(got, string(kept)) {
+					t.Errorf("read %d: admitted %q does not start after the skipped bytes of %q", i, kept, read)
+				}
+			}
+			if l.dropped != tt.dropped {
+				t.Errorf("dropped %d bytes, want %d", l.dropped, tt.dropped)
+			}
+		})
+	}
+}
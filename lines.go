@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// regexpList is a repeatable flag holding regular expressions.
+type regexpList []*regexp.Regexp
+
+// String returns the expressions separated by commas.
+func (l *regexpList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, re := range *l {
+		s = append(s, re.String())
+	}
+	return strings.Join(s, ",")
+}
+
+// Set compiles and appends an expression.
+func (l *regexpList) Set(v string) error {
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, re)
+	return nil
+}
+
+// matchAny reports whether any of the expressions matches the line.
+func (l regexpList) matchAny(line []byte) bool {
+	for _, re := range l {
+		if re.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// utf8BOM is the UTF-8 encoded byte order mark, stripped from the start of files with --strip-bom.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// lineTransform transforms a line of a file before it is output, e.g. to redact or reformat it.
+// line does not include the trailing newline. Returning nil drops the line.
+// Transforms are internal to the command; they are added in this package and enabled by flags.
+type lineTransform func(file string, line []byte) []byte
+
+// ansiEscape matches ANSI escape sequences: CSI sequences such as colors, OSC sequences
+// such as window titles, and two-byte escapes.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripANSI is a lineTransform removing ANSI escape sequences, for --strip-ansi.
+func stripANSI(_ string, line []byte) []byte {
+	return ansiEscape.ReplaceAll(line, nil)
+}
+
+// addLineTransform registers a transform applied, in registration order, to every line
+// selected for output. It must be called before tailing starts.
+func (a *app) addLineTransform(t lineTransform) {
+	a.transforms = append(a.transforms, t)
+}
+
+// lineState is the state of line-oriented processing of a single file.
+// It is kept per file, so lines and context of different files never mix.
+type lineState struct {
+	// partial holds the bytes after the last newline, waiting for the rest of the line.
+	partial []byte
+	// before holds the most recent lines that were not output, for leading context.
+	before [][]byte
+	// afterLeft is the number of lines still to output as trailing context of the last match.
+	afterLeft int
+	// lineNo is the number of complete lines seen, and lastOutput the number of the last line output.
+	lineNo     int
+	lastOutput int
+	// markerSeen is true once a line matching --start-after has been seen.
+	markerSeen bool
+	// sampled is the number of lines offered to --sample, see sampleKeep.
+	sampled int
+	// level is the --min-level severity of the last line with a level, inherited by the lines after it.
+	level int
+	// jsonPending holds the start of a --json-array element, waiting for the rest of it.
+	jsonPending []byte
+	// For --emit-offsets, readAt is the offset in the file of the data being emitted, partialAt
+	// the offset of partial, also used by outputOffset, lineAt the offset of the line being selected,
+	// and beforeAt the offsets of the lines in before.
+	readAt    int64
+	partialAt int64
+	lineAt    int64
+	beforeAt  []int64
+	// For --auto-timestamp, stamp is the timestamp format detected in the file, or nil if none is,
+	// once stampDetected is true. Until then, stampSniffed counts the bytes of the lines tried.
+	stamp         *timestampFormat
+	stampDetected bool
+	stampSniffed  int
+	// lastBlank is true if the last line selected for output was blank, for --squeeze-blank.
+	lastBlank bool
+	// skipLine is true while the rest of a line dropped by --max-buffer-memory is being skipped.
+	skipLine bool
+	// limitTail tells whether the last data read ended in the middle of a line, and whether
+	// --on-limit drop admitted that line, see admit.
+	limitTail limitTail
+}
+
+// resetLines discards the line state of a file after it was truncated or replaced.
+// The --start-after marker stays passed unless --start-after-reset is given.
+// What is read from the start then is new content, so there is no backlog left for --mark-live.
+func (a *app) resetLines(state *fileState) {
+	state.backlogEnd = 0
+	markerSeen := state.lines.markerSeen && !a.startAfterReset
+	state.lines = lineState{markerSeen: markerSeen}
+}
+
+// contextSeparator is output between non-contiguous blocks of context, like grep does.
+const contextSeparator = "--\n"
+
+// lineMode reports whether content is processed line by line.
+// Otherwise it is emitted as read, including any partial last line.
+func (a *app) lineMode() bool {
+	return len(a.include) > 0 || len(a.startAfter) > 0 || a.framing == framingLength || a.format != formatRaw || a.print0 || a.seqno || a.outputTemplate != "" || a.sample.enabled() || a.lineBuffered || len(a.transforms) > 0 || a.count || a.minLevel != "" || a.customDelimiter() || len(a.onMatch) > 0 || a.dedupWindow > 0 || a.maxLineRate > 0 || a.squeezeBlank || a.serveAddr != ""
+}
+
+// emitLines splits new data of a file into lines, and emits the selected ones.
+// An incomplete last line is kept until the rest of it has been read.
+// With --record-delimiter, lines end with the delimiter instead of a newline, including in the
+// rest of the line processing; a delimiter split across reads is found once the rest is read.
+func (a *app) emitLines(path string, state *fileState, data []byte) {
+	ls := &state.lines
+	at := ls.readAt
+	if ls.skipLine {
+		i := bytes.Index(data, a.delim)
+		if i < 0 {
+			return
+		}
+		ls.skipLine = false
+		data = data[i+len(a.delim):]
+		at += int64(i + len(a.delim))
+	}
+	if len(ls.partial) > 0 {
+		data = append(ls.partial, data...)
+		ls.partial = nil
+		at = ls.partialAt
+	}
+
+	var out bytes.Buffer
+	for len(data) > 0 {
+		i := bytes.Index(data, a.delim)
+		if i < 0 {
+			ls.partial = append([]byte(nil), data...)
+			ls.partialAt = at
+			break
+		}
+		end := i + len(a.delim)
+		ls.lineAt = at
+		a.selectLine(path, state, data[:end], &out)
+		data = data[end:]
+		at += int64(end)
+	}
+
+	if out.Len() > 0 {
+		a.emit(path, out.Bytes())
+	}
+}
+
+// selectLine decides whether a complete line, including its newline, is output,
+// and writes it to out together with any context lines due.
+func (a *app) selectLine(path string, state *fileState, line []byte, out *bytes.Buffer) {
+	ls := &state.lines
+	if len(a.startAfter) > 0 && !ls.markerSeen {
+		// Nothing is output up to and including the marker line.
+		ls.markerSeen = a.startAfter.matchAny(a.lineContent(line))
+		return
+	}
+	ls.lineNo++
+	// Commands run for every line read, whether it is output or not.
+	a.runOnMatch(path, a.lineContent(line))
+	if !a.levelKeep(state, line) || !a.dedupKeep(path, line) {
+		// Filtered lines are not output as context either.
+		return
+	}
+	if a.count {
+		// Only count the line; no content is output with --count.
+		a.countLine(path, a.lineContent(line))
+		return
+	}
+	if len(a.include) == 0 {
+		if !a.sampleKeep(path, state) {
+			return
+		}
+		a.outputLine(path, state, ls.lineNo, ls.lineAt, line, out)
+		ls.lastOutput = ls.lineNo
+		return
+	}
+
+	// With --sample, only the sampled matches count as matches; the others may still be context.
+	if a.include.matchAny(a.lineContent(line)) && a.sampleKeep(path, state) {
+		// Separate this block from the previous one if lines were skipped in between.
+		first := ls.lineNo - len(ls.before)
+		// Frames, formatted, NUL-terminated, or numbered records have no room for separators.
+		if (a.beforeCtx > 0 || a.afterCtx > 0) && a.framing != framingLength && a.format == formatRaw && !a.print0 && !a.seqno && a.lineTemplate == nil && ls.lastOutput > 0 && first > ls.lastOutput+1 {
+			out.WriteString(contextSeparator)
+		}
+		for i, b := range ls.before {
+			a.outputLine(path, state, first+i, ls.beforeAt[i], b, out)
+		}
+		ls.before, ls.beforeAt = ls.before[:0], ls.beforeAt[:0]
+		a.outputLine(path, state, ls.lineNo, ls.lineAt, line, out)
+		ls.lastOutput = ls.lineNo
+		ls.afterLeft = a.afterCtx
+		return
+	}
+
+	if ls.afterLeft > 0 {
+		a.outputLine(path, state, ls.lineNo, ls.lineAt, line, out)
+		ls.lastOutput = ls.lineNo
+		ls.afterLeft--
+		return
+	}
+
+	if a.beforeCtx > 0 {
+		if len(ls.before) == a.beforeCtx {
+			copy(ls.before, ls.before[1:])
+			ls.before = ls.before[:len(ls.before)-1]
+			copy(ls.beforeAt, ls.beforeAt[1:])
+			ls.beforeAt = ls.beforeAt[:len(ls.beforeAt)-1]
+		}
+		ls.before = append(ls.before, append([]byte(nil), line...))
+		ls.beforeAt = append(ls.beforeAt, ls.lineAt)
+	}
+}
+
+// outputLine applies the line transforms to a complete line, including its newline,
+// and writes the result to out unless a transform dropped it.
+// With --output-template, the result is rendered with the template; lineNo is the number of the line.
+// at is the offset of the line in the file for --emit-offsets, or -1 if it is unknown.
+// With --auto-timestamp, the timestamp of the line is parsed before the transforms change it.
+func (a *app) outputLine(path string, state *fileState, lineNo int, at int64, line []byte, out *bytes.Buffer) {
+	if a.squeezedBlank(state, line) {
+		return
+	}
+	span := lineSpan{at: at, n: len(line), stamp: a.lineStamp(path, state, line)}
+	if len(a.transforms) == 0 && a.lineTemplate == nil {
+		out.Write(line)
+		a.addSpan(span)
+		a.publishLine(path, a.lineContent(line))
+		return
+	}
+
+	content := a.lineContent(line)
+	for _, t := range a.transforms {
+		content = t(path, content)
+		if content == nil {
+			return
+		}
+	}
+	a.publishLine(path, content)
+	if a.lineTemplate != nil {
+		rec := record{File: a.displayPath(path), RealPath: path, Time: time.Now(), Line: content, LineNo: lineNo, Seq: a.nextSeq(), Tag: state.pattern, FileID: a.emittedFileID(path)}
+		if a.emitOffsets && at >= 0 {
+			rec.Offset, rec.Length, rec.HasOffset = at, len(line), true
+		}
+		rec.LogTime = span.stamp
+		b, err := a.lineTemplate.Format(rec)
+		if err != nil {
+			a.logError("rendering --output-template for a line of %s: %v\n", path, err)
+			return
+		}
+		out.Write(b)
+		return
+	}
+	out.Write(content)
+	out.Write(a.delim)
+	a.addSpan(span)
+}
+
+// squeezedBlank reports whether a line is left out by --squeeze-blank, as a blank line following
+// another one of the same file. A line holding only a carriage return is blank as well. Lines are
+// counted as they reach the output, after the selection and before the transforms.
+func (a *app) squeezedBlank(state *fileState, line []byte) bool {
+	if !a.squeezeBlank {
+		return false
+	}
+	blank := len(bytes.TrimSuffix(a.lineContent(line), []byte("\r"))) == 0
+	squeezed := blank && state.lines.lastBlank
+	state.lines.lastBlank = blank
+	return squeezed
+}
+
+// lineContent returns a complete line without its newline, or --record-delimiter.
+func (a *app) lineContent(line []byte) []byte {
+	return bytes.TrimSuffix(line, a.delim)
+}
+
+// outputOffset returns the offset in the file up to which its content has been output, or dropped:
+// the offset of a partial last line kept for the rest of it, or else where reading stopped.
+func (state *fileState) outputOffset() int64 {
+	if len(state.lines.partial) > 0 {
+		return state.lines.partialAt
+	}
+	return state.offset
+}
+
+// flushPartialLines processes the incomplete last line of every watched file as if it were complete.
+// It is used before exiting, so content without a trailing newline is not lost.
+func (a *app) flushPartialLines() {
+	if !a.lineMode() {
+		return
+	}
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		a.flushPartialLine(key.(string), value.(*fileState))
+		return true
+	})
+}
+
+// flushPartialLine processes the incomplete last line of a file, if any, as if it were complete.
+func (a *app) flushPartialLine(path string, state *fileState) {
+	ls := &state.lines
+	if len(ls.partial) == 0 {
+		return
+	}
+	line := append(ls.partial, a.delim...)
+	ls.partial = nil
+	ls.lineAt = ls.partialAt
+
+	var out bytes.Buffer
+	a.selectLine(path, state, line, &out)
+	if out.Len() > 0 {
+		a.emit(path, out.Bytes())
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSelectLineContext(t *testing.T) {
+	const data = "a\nb\nmatch 1\nc\nd\ne\nf\nmatch 2\ng\nmatch 3\nh\n"
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "no context",
+			args: []string{"--include", "match"},
+			want: "match 1\nmatch 2\nmatch 3\n",
+		},
+		{
+			name: "leading context",
+			args: []string{"--include", "match", "--before-context", "1"},
+			want: "b\nmatch 1\n--\nf\nmatch 2\ng\nmatch 3\n",
+		},
+		{
+			name: "trailing context",
+			args: []string{"--include", "match", "--after-context", "1"},
+			want: "match 1\nc\n--\nmatch 2\ng\nmatch 3\nh\n",
+		},
+		{
+			name: "overlapping context is output once",
+			args: []string{"--include", "match", "--context", "2"},
+			want: "a\nb\nmatch 1\nc\nd\ne\nf\nmatch 2\ng\nmatch 3\nh\n",
+		},
+		{
+			name: "context before the first match only",
+			args: []string{"--include", "match 1", "--context", "1"},
+			want: "b\nmatch 1\nc\n",
+		},
+		{
+			name: "one skipped line is separated",
+			args: []string{"--include", "match", "--before-context", "3"},
+			want: "a\nb\nmatch 1\n--\nd\ne\nf\nmatch 2\ng\nmatch 3\n",
+		},
+		{
+			name: "adjacent blocks have no separator",
+			args: []string{"--include", "match", "--before-context", "4"},
+			want: "a\nb\nmatch 1\nc\nd\ne\nf\nmatch 2\ng\nmatch 3\n",
+		},
+		{
+			name: "no separators in records",
+			args: []string{"--include", "match", "--context", "1", "--print0"},
+			want: "b\x00match 1\x00c\x00f\x00match 2\x00g\x00match 3\x00h\x00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tailAppended(t, data, tt.args...); got != tt.want {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectLineContextAcrossReads(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "", true)
+	tt := newTestTail(t, "--include", "match", "--context", "1", filepath.Join(dir, "*.log"))
+
+	// Trailing context and the separator carry over from one read to the next.
+	writeFile(t, path, "a\nmatch 1\n", false)
+	if got, want := tt.poll(), "a\nmatch 1\n"; got != want {
+		t.Errorf("first read: output %q, want %q", got, want)
+	}
+	writeFile(t, path, "b\nc\nd\nmatch 2\n", false)
+	if got, want := tt.poll(), "b\n--\nd\nmatch 2\n"; got != want {
+		t.Errorf("second read: output %q, want %q", got, want)
+	}
+}
+
+func TestSqueezeBlank(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		args []string
+		want string
+	}{
+		{"runs of blank lines", "a\n\n\n\nb\n\nc\n", nil, "a\n\nb\n\nc\n"},
+		{"leading blank lines", "\n\na\n", nil, "\na\n"},
+		{"carriage returns are blank", "a\r\n\r\n\r\nb\r\n", nil, "a\r\n\r\nb\r\n"},
+		{"spaces are not blank", "a\n \n \nb\n", nil, "a\n \n \nb\n"},
+		// Lines are squeezed after the selection, so lines left out in between do not count.
+		{"after the selection", "a\n\nskip\n\nb\n", []string{"--include", "^(a|b|)$"}, "a\n\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tailAppended(t, tt.data, append(tt.args, "--squeeze-blank")...); got != tt.want {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSqueezeBlankAcrossReads(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "", true)
+	tt := newTestTail(t, "--squeeze-blank", filepath.Join(dir, "*.log"))
+	writeFile(t, path, "a\n\n", false)
+	if got, want := tt.poll(), "a\n\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+	writeFile(t, path, "\n\nb\n", false)
+	if got, want := tt.poll(), "b\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+}
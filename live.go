@@ -0,0 +1,21 @@
+package main
+
+import "log"
+
+// writeLiveMarker notes, with --mark-live, that a file read from before the end caught up with the size
+// it had when it was added, so what follows was appended live. Raw output gets a
+// "--- path LIVE ---" line; the other formats have no room for it, so it is logged instead.
+// It is called with the output held, see pollCycle.
+func (a *app) writeLiveMarker(path string) {
+	shown := a.displayPath(path)
+	if a.formatter != nil || a.lineTemplate != nil || a.framing == framingLength || a.print0 {
+		log.Printf("Info: Read the backlog of %s; following it live.\n", path)
+		return
+	}
+	marker := []byte("--- " + shown + " LIVE ---\n")
+	if a.groupByFile {
+		a.groups.add(shown, a.emittedFileID(path), marker)
+		return
+	}
+	a.writeOutput(marker)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logSource is the file path under which log messages are written to the output with
+// --tee-stderr-errors-to-output. Watched files are shown by absolute path, so it cannot clash.
+const logSource = "ftail"
+
+// maxPendingLogs bounds the log messages waiting to be written to the output.
+// Further ones are only counted until the output catches up.
+const maxPendingLogs = 1024
+
+// logTimeLayout is the timestamp the standard logger prefixes messages with.
+const logTimeLayout = "2006/01/02 15:04:05 "
+
+// logRecord is a log message written to the output.
+type logRecord struct {
+	Seq   uint64    `json:"seq,omitempty"`
+	Level string    `json:"level"`
+	Time  time.Time `json:"time"`
+	Msg   string    `json:"msg"`
+}
+
+// logFormatter is implemented by formatters with a dedicated form for log messages.
+// Other formatters get them as lines of the file logSource.
+type logFormatter interface {
+	FormatLog(rec logRecord) ([]byte, error)
+}
+
+// FormatLog implements logFormatter.
+func (jsonFormatter) FormatLog(rec logRecord) ([]byte, error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// FormatLog implements logFormatter.
+func (logfmtFormatter) FormatLog(rec logRecord) ([]byte, error) {
+	var b bytes.Buffer
+	if rec.Seq > 0 {
+		b.WriteString("seq=")
+		b.WriteString(strconv.FormatUint(rec.Seq, 10))
+		b.WriteByte(' ')
+	}
+	b.WriteString("time=")
+	b.WriteString(rec.Time.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(rec.Level)
+	b.WriteString(" msg=")
+	writeLogfmtValue(&b, rec.Msg)
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// logTee is a second destination of the standard logger, besides standard error, for
+// --tee-stderr-errors-to-output. Log messages can come from any goroutine, so they are
+// queued here and written to the output by the polling goroutine, see writeLogRecords.
+type logTee struct {
+	mu      sync.Mutex
+	pending []logRecord
+	dropped int
+}
+
+// Write implements io.Writer, taking one log message per call as the standard logger does.
+func (t *logTee) Write(p []byte) (int, error) {
+	rec := parseLogMessage(p)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxPendingLogs {
+		t.dropped++
+	} else {
+		t.pending = append(t.pending, rec)
+	}
+	return len(p), nil
+}
+
+// parseLogMessage splits a message of the standard logger into its time, its level prefix,
+// e.g. "Error:", and the rest of it. Messages without a level are info.
+func parseLogMessage(p []byte) logRecord {
+	msg := strings.TrimSuffix(string(p), "\n")
+	rec := logRecord{Level: "info", Time: time.Now()}
+	if len(msg) >= len(logTimeLayout) {
+		if ts, err := time.ParseInLocation(logTimeLayout, msg[:len(logTimeLayout)], time.Local); err == nil {
+			rec.Time, msg = ts, msg[len(logTimeLayout):]
+		}
+	}
+	for _, level := range []string{"Error", "Warning", "Info", "Debug"} {
+		if rest, ok := strings.CutPrefix(msg, level+": "); ok {
+			rec.Level, msg = strings.ToLower(level), rest
+			break
+		}
+	}
+	rec.Msg = msg
+	return rec
+}
+
+// take returns and clears the queued log messages, with a note about dropped ones if any.
+func (t *logTee) take() []logRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recs := t.pending
+	t.pending = nil
+	if t.dropped > 0 {
+		recs = append(recs, logRecord{Level: "warning", Time: time.Now(), Msg: fmt.Sprintf("%d log messages were not written to the output", t.dropped)})
+		t.dropped = 0
+	}
+	return recs
+}
+
+// writeLogRecords writes the queued log messages to the output, in the --format of the content.
+// Formats without a dedicated form for them, and raw output, show them as lines of logSource.
+func (a *app) writeLogRecords() {
+	if a.logTee == nil {
+		return
+	}
+	for _, rec := range a.logTee.take() {
+		if f, ok := a.formatter.(logFormatter); ok {
+			rec.Seq = a.nextSeq()
+			b, err := f.FormatLog(rec)
+			if err != nil {
+				continue
+			}
+			if a.print0 {
+				b = append(bytes.TrimSuffix(b, []byte("\n")), 0)
+			}
+			a.writeOutput(b)
+			continue
+		}
+		line := []byte(strings.ToUpper(rec.Level[:1]) + rec.Level[1:] + ": " + strings.ReplaceAll(rec.Msg, "\n", " ") + string(a.delim))
+		if a.framing == framingLength {
+			a.writeFrames(logSource, line)
+		} else {
+			a.writeContent(logSource, "", line)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// readManifest reads the paths listed in a --manifest file, one per line.
+// Blank lines and lines starting with # are ignored. Relative paths are relative to the
+// directory of the manifest. The paths are returned as glob patterns matching them literally,
+// so the files are watched like files matched by the glob patterns.
+func readManifest(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		patterns = append(patterns, literalPattern(line))
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading manifest %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// literalPattern escapes the glob metacharacters of a path, so it only matches itself.
+// doublestar does not support escaping on Windows, where \ separates paths instead.
+func literalPattern(path string) string {
+	if filepath.Separator != '/' {
+		return path
+	}
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[]{}\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// literalPrefix marks a pattern argument as a literal path, e.g. literal:my[1].log.
+const literalPrefix = "literal:"
+
+// literalPatterns turns the arguments marked with literalPrefix, or all of them with --literal,
+// into patterns matching only the path given, see literalPattern.
+func literalPatterns(patterns []string, all bool) []string {
+	out := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if path, ok := strings.CutPrefix(p, literalPrefix); ok {
+			out = append(out, literalPattern(path))
+		} else if all {
+			out = append(out, literalPattern(p))
+		} else {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// refreshManifest re-reads the --manifest file, and makes its paths together with the given
+// glob patterns the current patterns if they changed. It reports whether they did.
+// If the manifest cannot be read, the paths read before are kept.
+func (a *app) refreshManifest(manifest string, static []string) bool {
+	if manifest == "" {
+		return false
+	}
+	entries, err := readManifest(manifest)
+	if err != nil {
+		a.logError("%v; keeping the %d files listed before\n", err, len(a.manifestEntries))
+		return false
+	}
+	if slices.Equal(entries, a.manifestEntries) {
+		return false
+	}
+	a.manifestEntries = entries
+	a.setPatterns(append(slices.Clip(static), entries...))
+	return true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLiteralPatterns(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("doublestar does not support escaping on Windows")
+	}
+	dir := t.TempDir()
+	bracket := filepath.Join(dir, "app[1].log")
+	plain := filepath.Join(dir, "app1.log")
+	writeFile(t, bracket, "", true)
+	writeFile(t, plain, "", true)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		// As a glob, the brackets are a character class matching app1.log only.
+		{"glob", []string{bracket}, []string{plain}},
+		{"literal prefix", []string{literalPrefix + bracket}, []string{bracket}},
+		{"--literal", []string{"--literal", bracket}, []string{bracket}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tt := newTestTail(t, tc.args...)
+			got := tt.watched()
+			if len(got) != len(tc.want) {
+				t.Errorf("watching %v, want %v", got, tc.want)
+			}
+			for _, path := range tc.want {
+				if !got[path] {
+					t.Errorf("not watching %s; watching %v", path, got)
+				}
+			}
+
+			// The file is read like any other.
+			writeFile(t, tc.want[0], "line\n", false)
+			if got := tt.poll(); got != "line\n" {
+				t.Errorf("output %q, want %q", got, "line\n")
+			}
+		})
+	}
+}
+
+func TestLiteralPattern(t *testing.T) {
+	if filepath.Separator != '/' {
+		t.Skip("doublestar does not support escaping on Windows")
+	}
+	for path, want := range map[string]string{
+		"/var/log/app.log":     "/var/log/app.log",
+		"/var/log/app[1].log":  `/var/log/app\[1\].log`,
+		"/logs/{a,b}/*.log?":   `/logs/\{a,b\}/\*.log\?`,
+		`/logs/back\slash.log`: `/logs/back\\slash.log`,
+	} {
+		if got := literalPattern(path); got != want {
+			t.Errorf("literalPattern(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// Values of --merge-by.
+const (
+	mergeNone     = ""
+	mergeBasename = "basename"
+	mergeRegex    = "regex"
+)
+
+// validMerge checks --merge-by and compiles its --merge-regex, which is nil unless merging by regex.
+func validMerge(by, expr string) (*regexp.Regexp, error) {
+	switch by {
+	case mergeNone, mergeBasename:
+		if expr != "" {
+			return nil, errors.New("--merge-regex requires --merge-by regex")
+		}
+		return nil, nil
+	case mergeRegex:
+		if expr == "" {
+			return nil, errors.New("--merge-by regex requires --merge-regex")
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --merge-regex: %w", err)
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("--merge-by must be %s or %s, got %q", mergeBasename, mergeRegex, by)
+}
+
+// mergeKey returns the name of the stream a file is merged into with --merge-by: its base name,
+// or the first capture group of --merge-regex in its path (the whole match without groups).
+// ok is false if the file is not merged, because merging is off or the regex does not match.
+func (a *app) mergeKey(path string) (key string, ok bool) {
+	switch a.mergeBy {
+	case mergeBasename:
+		return filepath.Base(path), true
+	case mergeRegex:
+		m := a.mergeRe.FindStringSubmatch(path)
+		if m == nil {
+			return "", false
+		}
+		if len(m) > 1 {
+			return m[1], true
+		}
+		return m[0], true
+	}
+	return "", false
+}
+
+// mergeTag is a lineTransform prefixing each line of a merged file with the name of its
+// directory, e.g. "[instance-1] ", so the files of a merged stream can be told apart.
+func (a *app) mergeTag(path string, line []byte) []byte {
+	if _, ok := a.mergeKey(path); !ok {
+		return line
+	}
+	tag := "[" + filepath.Base(filepath.Dir(path)) + "] "
+	return append([]byte(tag), line...)
+}
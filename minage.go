@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// tooYoung reports whether a file found after startup is skipped by --min-file-age, recording when
+// it was first found. The scan adds it once it has been around for the age; as all of its content
+// was written since it was found, it is then read from the start. Files found at startup are old enough.
+func (a *app) tooYoung(realPath string, initial bool) bool {
+	if a.minFileAge <= 0 || initial {
+		return false
+	}
+	found, _ := a.youngFiles.LoadOrStore(realPath, time.Now())
+	if time.Since(found.(time.Time)) < a.minFileAge {
+		return true
+	}
+	a.youngFiles.Delete(realPath)
+	a.agedFiles.Store(realPath, true)
+	return false
+}
+
+// pruneYoungFiles forgets the files skipped by --min-file-age that are gone, e.g. transient
+// temporary files, so they do not pile up.
+func (a *app) pruneYoungFiles() {
+	a.youngFiles.Range(func(key, _ interface{}) bool {
+		if _, err := a.source.Stat(key.(string)); os.IsNotExist(err) {
+			a.youngFiles.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMinFileAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.log")
+	writeFile(t, old, "old\n", true)
+	tt := newTestTail(t, "--min-file-age", "1h", filepath.Join(dir, "*.log"))
+	// Files found at startup are old enough.
+	if !tt.watched()[old] {
+		t.Errorf("%s found at startup is not watched", old)
+	}
+
+	young := filepath.Join(dir, "young.log")
+	writeFile(t, young, "first\n", true)
+	tt.scan()
+	tt.scan()
+	if tt.watched()[young] {
+		t.Fatalf("%s watched before it is an hour old", young)
+	}
+	if got := tt.poll(); got != "" {
+		t.Errorf("output %q before the file is old enough", got)
+	}
+
+	// Once old enough, all of the file is read, as it was written since it was found.
+	tt.a.youngFiles.Store(young, time.Now().Add(-time.Hour))
+	writeFile(t, young, "second\n", false)
+	tt.scan()
+	if !tt.watched()[young] {
+		t.Fatalf("%s not watched once old enough", young)
+	}
+	if got, want := tt.poll(), "first\nsecond\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+
+	// Files removed while too young are forgotten.
+	temp := filepath.Join(dir, "temp.log")
+	writeFile(t, temp, "", true)
+	tt.scan()
+	if err := os.Remove(temp); err != nil {
+		t.Fatal(err)
+	}
+	tt.scan()
+	if _, ok := tt.a.youngFiles.Load(temp); ok {
+		t.Errorf("removed %s still recorded as too young", temp)
+	}
+}
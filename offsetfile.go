@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxOffsetFileSize bounds what is read of a companion offset file; it only holds a number.
+const maxOffsetFileSize = 64
+
+// isOffsetFile reports whether path is a companion offset file of --offset-from,
+// which is not tailed itself even if a pattern matches it.
+func (a *app) isOffsetFile(path string) bool {
+	return a.offsetFrom != "" && strings.HasSuffix(path, "."+a.offsetFrom)
+}
+
+// offsetFromFile reads the initial offset of a file from its companion offset file with --offset-from,
+// i.e. path.<suffix> holding a byte offset in decimal, as left by another log agent.
+// ok is false without --offset-from, if there is no such file, or its content is not an offset within size.
+func (a *app) offsetFromFile(path string, size int64) (offset int64, ok bool) {
+	if a.offsetFrom == "" {
+		return 0, false
+	}
+	offsetPath := path + "." + a.offsetFrom
+	file, err := a.openFile(offsetPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: reading offset file %s: %v; reading %s from the end\n", offsetPath, err, path)
+		}
+		return 0, false
+	}
+	defer a.closeFile(file)
+
+	content, err := io.ReadAll(io.LimitReader(file, maxOffsetFileSize))
+	if err == nil {
+		offset, err = strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	}
+	if err != nil || offset < 0 || offset > size {
+		log.Printf("Warning: offset file %s does not hold an offset within the %d bytes of %s; reading it from the end\n", offsetPath, size, path)
+		return 0, false
+	}
+	log.Printf("Info: Reading %s from offset %d given by %s\n", path, offset, offsetPath)
+	return offset, true
+}
@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+// lineSpan is what the formatter is told about an output line besides its content: where it is in
+// its file, for --emit-offsets, as the offset of its first byte and its length including the newline
+// or --record-delimiter, and its timestamp, for --auto-timestamp. at is -1 if it is not known,
+// e.g. for replayed lines, and stamp is zero if the line has none.
+type lineSpan struct {
+	at    int64
+	n     int
+	stamp time.Time
+}
+
+// addSpan records the span of a line just written to the output buffer of emitLines, so the formatter
+// can annotate it, see writeFormatted. The spans are consumed in order, one per line written, and
+// discarded once the content was emitted.
+func (a *app) addSpan(span lineSpan) {
+	if (a.emitOffsets || a.autoTimestamp) && a.lineTemplate == nil {
+		a.spans = append(a.spans, span)
+	}
+}
+
+// nextSpan returns the span of the next line formatted, or ok false if there is none.
+func (a *app) nextSpan() (span lineSpan, ok bool) {
+	if len(a.spans) == 0 {
+		return lineSpan{}, false
+	}
+	span, a.spans = a.spans[0], a.spans[1:]
+	return span, true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onMatchQueueSize bounds the --on-match commands waiting for a worker. Further ones are dropped.
+const onMatchQueueSize = 64
+
+// onMatchAction is a command run for the lines matching a regular expression, see --on-match.
+type onMatchAction struct {
+	re *regexp.Regexp
+	// command is the program and its arguments. It is run directly, not through a shell.
+	command []string
+}
+
+// onMatchList is the repeatable --on-match flag, each value being REGEXP:COMMAND.
+type onMatchList []onMatchAction
+
+// String returns the actions as given.
+func (l *onMatchList) String() string {
+	s := make([]string, 0, len(*l))
+	for _, m := range *l {
+		s = append(s, m.re.String()+":"+strings.Join(m.command, " "))
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses and appends an action. The expression ends at the first colon;
+// a colon in the expression can be written as \x3a.
+func (l *onMatchList) Set(v string) error {
+	expr, command, ok := strings.Cut(v, ":")
+	if !ok {
+		return errors.New("expected REGEXP:COMMAND")
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	words := strings.Fields(command)
+	if len(words) == 0 {
+		return errors.New("the command is empty")
+	}
+	*l = append(*l, onMatchAction{re: re, command: words})
+	return nil
+}
+
+// matchJob is a command to run for a matching line.
+type matchJob struct {
+	command []string
+	path    string
+	line    []byte
+}
+
+// matchRunner runs the --on-match commands in a pool of workers, so a slow command does not
+// hold up the read loop. Commands are rate-limited by a token bucket; those over the rate, or
+// that find the queue full, are dropped and counted, so a flood of matches cannot start
+// processes without bound.
+type matchRunner struct {
+	jobs    chan matchJob
+	limiter *throughputLimiter
+	timeout time.Duration
+	wg      sync.WaitGroup
+	// dropped is the number of commands dropped since lastReport. It is only accessed from the polling goroutine.
+	dropped    int
+	lastReport time.Time
+}
+
+// newMatchRunner starts the workers running --on-match commands, at most rate per second.
+func newMatchRunner(workers int, rate int64, timeout time.Duration) *matchRunner {
+	r := &matchRunner{
+		jobs:       make(chan matchJob, onMatchQueueSize),
+		limiter:    newThroughputLimiter(rate),
+		timeout:    timeout,
+		lastReport: time.Now(),
+	}
+	for range workers {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for job := range r.jobs {
+				r.exec(job)
+			}
+		}()
+	}
+	return r
+}
+
+// offer queues a command unless it is over the rate or the queue is full.
+func (r *matchRunner) offer(job matchJob, now time.Time) {
+	if r.limiter.available(now) < 1 {
+		r.dropped++
+		return
+	}
+	select {
+	case r.jobs <- job:
+		r.limiter.take(1)
+	default:
+		r.dropped++
+	}
+}
+
+// exec runs a command with the path of the file and the line as its last two arguments,
+// and the line, with a newline, on its standard input. Failures are logged with the output.
+func (r *matchRunner) exec(job matchJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	args := append(append([]string(nil), job.command[1:]...), job.path, string(job.line))
+	cmd := exec.CommandContext(ctx, job.command[0], args...)
+	cmd.Stdin = bytes.NewReader(append(job.line, '\n'))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %v", r.timeout)
+		}
+		if out = bytes.TrimSpace(out); len(out) > 0 {
+			err = fmt.Errorf("%w: %s", err, out)
+		}
+		log.Printf("Warning: --on-match command %s for a line of %s failed: %v\n", job.command[0], job.path, err)
+	}
+}
+
+// report logs the commands dropped since the last report, at most every limitReportInterval
+// unless force is true.
+func (r *matchRunner) report(now time.Time, force bool) {
+	if r.dropped == 0 || !force && now.Sub(r.lastReport) < limitReportInterval {
+		return
+	}
+	log.Printf("Warning: %d --on-match commands were not run over --on-match-rate or with all workers busy in the last %v\n", r.dropped, now.Sub(r.lastReport).Round(100*time.Millisecond))
+	r.dropped, r.lastReport = 0, now
+}
+
+// close waits for the queued and running commands, which are bounded by the timeout.
+func (r *matchRunner) close() {
+	close(r.jobs)
+	r.wg.Wait()
+}
+
+// runOnMatch offers the --on-match commands whose expression matches a line, without its newline.
+func (a *app) runOnMatch(path string, line []byte) {
+	if a.matches == nil {
+		return
+	}
+	for _, m := range a.onMatch {
+		if m.re.Match(line) {
+			a.matches.offer(matchJob{command: m.command, path: path, line: append([]byte(nil), line...)}, time.Now())
+		}
+	}
+}
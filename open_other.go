@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "os"
+
+// openShared opens a file for reading. Opening a file does not lock it out of other processes
+// outside Windows, so this is just os.Open.
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
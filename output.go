@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"time"
+)
+
+// outputBufferSize is the size of the buffer in front of standard output.
+const outputBufferSize = 64 * 1024
+
+// newOutput creates the writer for emitted content, in front of standard output or the --output file.
+// How often it is flushed depends on --buffered and --line-buffered, see writeOutput.
+func newOutput(w io.Writer) *bufio.Writer {
+	return bufio.NewWriterSize(w, outputBufferSize)
+}
+
+// writeOutput writes emitted content and flushes it according to the buffering mode:
+//   - by default, each write is flushed right away, as if the output were unbuffered.
+//   - with --buffered, writes are only flushed at the end of each poll cycle by flushOutput,
+//     trading latency for fewer system calls.
+//   - with --line-buffered, which takes precedence over --buffered, each complete line
+//     is flushed as soon as it is written, so pipes such as `ftail ... | grep` see it immediately.
+//     Lines end with a NUL byte instead of a newline with --print0, or with the --record-delimiter.
+func (a *app) writeOutput(data []byte) {
+	a.openBatch()
+	if !a.lineBuffered {
+		_, _ = a.out.Write(data)
+		if !a.buffered {
+			_ = a.out.Flush()
+		}
+		return
+	}
+
+	terminator := []byte(a.delim)
+	if a.print0 {
+		terminator = []byte{0}
+	}
+	for len(data) > 0 {
+		i := bytes.Index(data, terminator)
+		if i < 0 {
+			_, _ = a.out.Write(data)
+			return
+		}
+		end := i + len(terminator)
+		_, _ = a.out.Write(data[:end])
+		_ = a.out.Flush()
+		data = data[end:]
+	}
+}
+
+// flushOutput writes out any buffered content. It is called at the end of each poll cycle
+// and before exiting.
+func (a *app) flushOutput() {
+	_ = a.out.Flush()
+	if a.split != nil {
+		a.split.flush()
+	}
+}
+
+// maxGroupBytes bounds the content collected by --group-by-file. Once reached,
+// the groups are written out before --group-window has passed.
+const maxGroupBytes = 1024 * 1024
+
+// fileGroups collects content per file, in the order the files first had new content.
+type fileGroups struct {
+	order []string
+	data  map[string][]byte
+	// ids holds the --emit-file-id identity of the file whose content was last added to each group.
+	ids map[string]string
+	// size is the number of bytes collected, and since when the oldest of them was collected.
+	size  int
+	since time.Time
+}
+
+// add appends new content of a file to its group.
+func (g *fileGroups) add(path, id string, data []byte) {
+	if g.data == nil {
+		g.data = make(map[string][]byte)
+		g.ids = make(map[string]string)
+	}
+	if g.size == 0 {
+		g.since = time.Now()
+	}
+	if _, ok := g.data[path]; !ok {
+		g.order = append(g.order, path)
+	}
+	g.data[path] = append(g.data[path], data...)
+	g.ids[path] = id
+	g.size += len(data)
+}
+
+// flushGroups writes out the content collected by --group-by-file, one block per file,
+// once --group-window has passed since the oldest content was collected, or right away if force is true.
+func (a *app) flushGroups(force bool) {
+	g := &a.groups
+	if g.size == 0 || !force && time.Since(g.since) < a.groupWindow {
+		return
+	}
+	for _, path := range g.order {
+		a.writeContent(path, g.ids[path], g.data[path])
+	}
+	*g = fileGroups{}
+}
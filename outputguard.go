@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Policies of --on-output-error.
+const (
+	onOutputErrorDrop = "drop"
+	onOutputErrorExit = "exit"
+)
+
+// outputQueueSize is the number of writes queued for a slow output before writes wait for it.
+const outputQueueSize = 64
+
+// errOutputBlocked is reported when the output did not accept a write within --output-timeout.
+var errOutputBlocked = errors.New("output blocked for longer than --output-timeout")
+
+// guardedWriter writes to the output from its own goroutine, so an output that blocks, such as
+// a FIFO nobody reads or a stalled network sink, cannot stall the read loop. Writes are queued,
+// and a write that cannot be queued within the timeout, or that fails, is handled by the
+// --on-output-error policy: dropped and counted, or reported to fail to stop ftail. Each queued
+// write is tried on its own, so the output recovers with the first one that succeeds.
+type guardedWriter struct {
+	w       io.Writer
+	chunks  chan []byte
+	timeout time.Duration
+	policy  string
+	fail    func(error)
+	done    chan struct{}
+
+	mu sync.Mutex
+	// dropping is true while writes are being dropped, and dropped counts them and their bytes.
+	dropping     bool
+	dropped      int
+	droppedBytes int
+}
+
+// newGuardedWriter starts writing to w from a goroutine. fail is called on failure with the exit policy.
+func newGuardedWriter(w io.Writer, timeout time.Duration, policy string, fail func(error)) *guardedWriter {
+	g := &guardedWriter{
+		w:       w,
+		chunks:  make(chan []byte, outputQueueSize),
+		timeout: timeout,
+		policy:  policy,
+		fail:    fail,
+		done:    make(chan struct{}),
+	}
+	go g.run()
+	return g
+}
+
+// run writes the queued chunks to the output.
+func (g *guardedWriter) run() {
+	defer close(g.done)
+	for chunk := range g.chunks {
+		if _, err := g.w.Write(chunk); err != nil {
+			g.drop(fmt.Errorf("writing output: %w", err), len(chunk))
+			continue
+		}
+		g.recovered()
+	}
+}
+
+// Write queues a copy of p. It never returns an error, so the buffer in front of it
+// keeps working once the output recovers; failed writes are handled by drop.
+func (g *guardedWriter) Write(p []byte) (int, error) {
+	chunk := append([]byte(nil), p...)
+	select {
+	case g.chunks <- chunk:
+		return len(p), nil
+	default:
+	}
+	// While dropping, do not wait for the output again on every write.
+	g.mu.Lock()
+	dropping := g.dropping
+	g.mu.Unlock()
+	if dropping {
+		g.drop(errOutputBlocked, len(p))
+		return len(p), nil
+	}
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case g.chunks <- chunk:
+	case <-timer.C:
+		g.drop(errOutputBlocked, len(p))
+	}
+	return len(p), nil
+}
+
+// drop handles a write that failed or could not be queued, according to the policy.
+func (g *guardedWriter) drop(err error, n int) {
+	if g.policy == onOutputErrorExit {
+		g.fail(err)
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.dropping {
+		log.Printf("Warning: %v; dropping output until it recovers\n", err)
+		g.dropping = true
+	}
+	g.dropped++
+	g.droppedBytes += n
+}
+
+// recovered logs how much output was dropped once writes succeed again.
+func (g *guardedWriter) recovered() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.dropping {
+		return
+	}
+	log.Printf("Info: Output recovered after dropping %d writes (%d bytes).\n", g.dropped, g.droppedBytes)
+	g.dropping, g.dropped, g.droppedBytes = false, 0, 0
+}
+
+// Close waits up to the timeout for the queued writes to reach the output.
+func (g *guardedWriter) Close() {
+	close(g.chunks)
+	select {
+	case <-g.done:
+	case <-time.After(g.timeout):
+		log.Printf("Warning: output still blocked at exit; queued output is lost\n")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.dropped > 0 {
+		log.Printf("Warning: %d writes (%d bytes) of output were dropped.\n", g.dropped, g.droppedBytes)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyWriter fails as many writes as failures says, and collects the bytes of the ones after.
+type flakyWriter struct {
+	mu       sync.Mutex
+	failures int
+	buf      bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return 0, errors.New("broken pipe")
+	}
+	return w.buf.Write(p)
+}
+
+func (w *flakyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestGuardedWriterRecovers(t *testing.T) {
+	w := &flakyWriter{failures: 1}
+	g := newGuardedWriter(w, time.Second, onOutputErrorDrop, func(err error) {
+		t.Errorf("fail called with the drop policy: %v", err)
+	})
+
+	// Wait for the failed write to be handled before the next one, so the queue is empty
+	// when it fails.
+	_, _ = g.Write([]byte("lost\n"))
+	deadline := time.Now().Add(time.Second)
+	for {
+		g.mu.Lock()
+		dropping := g.dropping
+		g.mu.Unlock()
+		if dropping {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the failed write was not dropped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	_, _ = g.Write([]byte("one\n"))
+	_, _ = g.Write([]byte("two\n"))
+	g.Close()
+
+	if got, want := w.String(), "one\ntwo\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+	if g.dropping || g.dropped != 0 {
+		t.Errorf("still dropping after a successful write: dropping %v, dropped %d", g.dropping, g.dropped)
+	}
+}
+
+func TestGuardedWriterExitPolicy(t *testing.T) {
+	w := &flakyWriter{failures: 1}
+	failed := make(chan error, 1)
+	g := newGuardedWriter(w, time.Second, onOutputErrorExit, func(err error) {
+		failed <- err
+	})
+	_, _ = g.Write([]byte("lost\n"))
+	g.Close()
+
+	select {
+	case err := <-failed:
+		if err == nil {
+			t.Error("fail called without an error")
+		}
+	default:
+		t.Error("fail not called for a failed write with the exit policy")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Values of --pause-mode.
+const (
+	// pauseHold stops reading while paused; the offsets stay where they are,
+	// and the content written meanwhile is output on resume.
+	pauseHold = "hold"
+	// pauseDrop keeps reading while paused, but drops the content instead of outputting it.
+	pauseDrop = "drop"
+)
+
+// validPauseMode checks a --pause-mode value.
+func validPauseMode(v string) error {
+	switch v {
+	case pauseHold, pauseDrop:
+		return nil
+	}
+	return fmt.Errorf("--pause-mode must be %s or %s, got %q", pauseHold, pauseDrop, v)
+}
+
+// handlePauseSignals pauses following on a signal, and resumes it on the next one.
+func (a *app) handlePauseSignals(signals <-chan os.Signal) {
+	for range signals {
+		if !a.paused.Load() {
+			a.paused.Store(true)
+			log.Printf("Info: Paused (--pause-mode %s); send the signal again to resume.\n", a.pauseMode)
+			continue
+		}
+		a.paused.Store(false)
+		if dropped := a.pauseDropped.Swap(0); dropped > 0 {
+			log.Printf("Info: Resumed; %d bytes of content were dropped while paused.\n", dropped)
+		} else {
+			log.Printf("Info: Resumed.\n")
+		}
+	}
+}
+
+// holdPaused reports whether the poll cycle is skipped, because following is paused with --pause-mode hold.
+func (a *app) holdPaused() bool {
+	return a.pauseMode == pauseHold && a.paused.Load()
+}
+
+// dropPaused reports whether content about to be emitted is dropped, because following is paused
+// with --pause-mode drop. The dropped bytes are counted and reported on resume.
+func (a *app) dropPaused(n int) bool {
+	if a.pauseMode != pauseDrop || !a.paused.Load() {
+		return false
+	}
+	a.pauseDropped.Add(int64(n))
+	return true
+}
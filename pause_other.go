@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// pauseSignals is empty, as this platform has no SIGUSR1; following cannot be paused.
+var pauseSignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// pauseSignals are the signals pausing and resuming following.
+var pauseSignals = []os.Signal{syscall.SIGUSR1}
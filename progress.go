@@ -0,0 +1,107 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// progressChunkSize is the most read from a file per poll with --progress, so the progress
+// of large files can be reported while they are read. The next poll follows right away.
+const progressChunkSize = 16 << 20
+
+// progressTracker logs how far --stop-at-eof has read the watched files, for --progress.
+// It is only accessed from the polling goroutine.
+type progressTracker struct {
+	interval time.Duration
+	// start is when the files were first seen, and first their offsets then, so the ETA is
+	// estimated from the average rate since.
+	start      map[string]time.Time
+	first      map[string]int64
+	lastReport time.Time
+}
+
+// newProgressTracker creates a tracker logging every interval.
+func newProgressTracker(interval time.Duration) *progressTracker {
+	return &progressTracker{
+		interval:   interval,
+		start:      make(map[string]time.Time),
+		first:      make(map[string]int64),
+		lastReport: time.Now(),
+	}
+}
+
+// eta estimates the time to read the remaining bytes at the average rate since start.
+// ok is false if nothing was read yet.
+func eta(read, remaining int64, elapsed time.Duration) (d time.Duration, ok bool) {
+	if read <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / float64(read) * float64(elapsed)).Round(time.Second), true
+}
+
+// etaString formats an ETA for the progress messages.
+func etaString(d time.Duration, ok bool) string {
+	if !ok {
+		return "ETA unknown"
+	}
+	return "ETA " + d.String()
+}
+
+// reportProgress logs, every --progress-interval, the percentage read and the ETA of each watched
+// file not read to the end yet, and of all of them together. It is called every poll cycle,
+// and before the first one, to note where and when each file started.
+func (a *app) reportProgress(now time.Time) {
+	p := a.progressLog
+	if p == nil {
+		return
+	}
+	report := now.Sub(p.lastReport) >= p.interval
+	if report {
+		p.lastReport = now
+	}
+	var files, complete int
+	var totalRead, totalSize, totalOffset int64
+	var earliest time.Time
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		path := key.(string)
+		offset := value.(*fileState).offset
+		if _, ok := p.start[path]; !ok {
+			p.start[path], p.first[path] = now, offset
+		}
+		if !report {
+			return true
+		}
+		fi, err := a.source.Stat(path)
+		if err != nil {
+			return true
+		}
+		size := fi.Size()
+		files++
+		totalSize += size
+		totalOffset += min(offset, size)
+		read := offset - p.first[path]
+		totalRead += read
+		if earliest.IsZero() || p.start[path].Before(earliest) {
+			earliest = p.start[path]
+		}
+		if offset >= size {
+			complete++
+			return true
+		}
+		d, ok := eta(read, size-offset, now.Sub(p.start[path]))
+		log.Printf("Info: Progress: %s %.1f%% (%s of %s), %s\n", path, percent(offset, size), formatBytes(float64(offset)), formatBytes(float64(size)), etaString(d, ok))
+		return true
+	})
+	if files > 1 && complete < files {
+		d, ok := eta(totalRead, totalSize-totalOffset, now.Sub(earliest))
+		log.Printf("Info: Progress: %d of %d files read to the end, %.1f%% of %s overall, %s\n", complete, files, percent(totalOffset, totalSize), formatBytes(float64(totalSize)), etaString(d, ok))
+	}
+}
+
+// percent returns the percentage of size that offset is at. An empty file is complete.
+func percent(offset, size int64) float64 {
+	if size <= 0 {
+		return 100
+	}
+	return 100 * float64(min(offset, size)) / float64(size)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// lineRateWindow is how long the line rate of a file is measured for --max-line-rate-per-file
+// before deciding whether to sample it.
+const lineRateWindow = time.Second
+
+// lineRate measures the line rate of a file and samples it while it floods, for --max-line-rate-per-file.
+type lineRate struct {
+	// windowStart is when the current measurement began, and lines the lines offered since.
+	windowStart time.Time
+	lines       int
+	// sampling is true while the file is over the rate, and rate the rate last measured.
+	sampling bool
+	rate     float64
+	// offered and skipped count the lines offered and skipped while sampling since lastNotice.
+	offered    int
+	skipped    int
+	lastNotice time.Time
+}
+
+// rateSampleKeep reports whether a line of a file is kept by --max-line-rate-per-file. A file is
+// output in full until its rate over a lineRateWindow exceeds the maximum. Then only every
+// --rate-sample-ratio line is output, with a notice every limitReportInterval, until a window
+// is at or below the maximum again.
+func (a *app) rateSampleKeep(path string, state *fileState) bool {
+	if a.maxLineRate <= 0 {
+		return true
+	}
+	r := &state.rate
+	now := time.Now()
+	if r.windowStart.IsZero() {
+		r.windowStart = now
+	}
+	r.lines++
+	if elapsed := now.Sub(r.windowStart); elapsed >= lineRateWindow {
+		r.rate = float64(r.lines) / elapsed.Seconds()
+		r.windowStart, r.lines = now, 0
+		over := r.rate > float64(a.maxLineRate)
+		switch {
+		case over && !r.sampling:
+			log.Printf("Warning: %s exceeds --max-line-rate-per-file %d at %.0f lines/s; outputting 1 of every %d lines until it calms down\n", path, a.maxLineRate, r.rate, a.rateSampleRatio)
+			r.sampling, r.offered, r.skipped, r.lastNotice = true, 0, 0, now
+		case !over && r.sampling:
+			log.Printf("Info: %s is back at %.0f lines/s; following it in full again after skipping %d lines\n", path, r.rate, r.skipped)
+			r.sampling = false
+		}
+	}
+	if !r.sampling {
+		return true
+	}
+	if now.Sub(r.lastNotice) >= limitReportInterval {
+		log.Printf("Info: sampling active for %s, %.0f lines/s; skipped %d lines in the last %v\n", path, r.rate, r.skipped, now.Sub(r.lastNotice).Round(100*time.Millisecond))
+		r.skipped, r.lastNotice = 0, now
+	}
+	keep := r.offered%a.rateSampleRatio == 0
+	r.offered++
+	if !keep {
+		r.skipped++
+	}
+	return keep
+}
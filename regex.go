@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+)
+
+// compileRegexPattern compiles a --regex pattern. The walk of regexp patterns starts at --root,
+// as a regexp has no static directory part that could tell where matching files may be.
+func compileRegexPattern(raw, root string, d discovery) globPattern {
+	absBase, realBase := resolveBase(root, d)
+	return globPattern{raw: raw, base: root, absBase: absBase, realBase: realBase, re: regexp.MustCompile(raw)}
+}
+
+// walkRegex walks the base directory of a --regex pattern, and calls visit for each file
+// whose absolute path matches the regexp, like doublestar.GlobWalk does for glob patterns.
+// Unreadable directories are skipped. Symlinked directories are not descended into.
+func walkRegex(fsys fs.FS, g globPattern, visit func(path string, d fs.DirEntry) error) error {
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if !g.re.MatchString(filepath.Join(g.absBase, filepath.FromSlash(path))) {
+			return nil
+		}
+		return visit(path, d)
+	})
+}
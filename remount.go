@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// checkRemounts notices when the directory of a watched file comes to be on another device, as when
+// its filesystem is unmounted and mounted again, e.g. a network share reconnecting. The watch of
+// the directory is then stale, so it is established again, and its files are checked, see
+// remountFiles. It is called by the polling goroutine, at most every --scan-interval.
+// Devices are only known on Unix.
+func (a *app) checkRemounts(now time.Time) {
+	if now.Sub(a.lastRemountCheck) < a.remountInterval {
+		return
+	}
+	a.lastRemountCheck = now
+	dirs := make(map[string]bool)
+	a.watchedFiles.Range(func(key, _ interface{}) bool {
+		dirs[filepath.Dir(key.(string))] = true
+		return true
+	})
+	for dir := range dirs {
+		fi, err := a.source.Stat(dir)
+		if err != nil {
+			continue
+		}
+		dev, ok := deviceOf(fi)
+		if !ok {
+			continue
+		}
+		prev, loaded := a.dirDevices.Swap(dir, dev)
+		if !loaded || prev.(uint64) == dev {
+			continue
+		}
+		log.Printf("Warning: Directory %s is on device %d instead of %d, as if its filesystem was remounted; restarting its watch.\n", dir, dev, prev)
+		// The watcher may have dropped the stale watch on the unmount already, so failing to remove it is expected.
+		if watchErr, watched := a.watchedDirs.LoadAndDelete(dir); watched && watchErr == nil {
+			_ = a.dirWatcher.Remove(dir)
+			a.dirWatches.Add(-1)
+			a.addToWatchDir(dir)
+		}
+		a.remountFiles(dir)
+	}
+}
+
+// remountFiles checks the identities of the watched files of a remounted directory. Their device
+// changed with it, which would make each of them look replaced and be read again from the start.
+// A file with the same inode as before is the same file, so it keeps its offset and nothing is
+// output again. Other files are handled as replaced when they are next polled.
+func (a *app) remountFiles(dir string) {
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		path, state := key.(string), value.(*fileState)
+		if filepath.Dir(path) != dir {
+			return true
+		}
+		fi, err := a.source.Stat(path)
+		if err != nil {
+			return true
+		}
+		id, ok := a.source.Identity(path, fi)
+		if !ok || id == state.id {
+			return true
+		}
+		if sameFileIndex(state.id, id) {
+			log.Printf("Info: File %s kept its inode across the remount, continuing at offset %d.\n", path, state.offset)
+			state.id = id
+		}
+		return true
+	})
+}
+
+// sameFileIndex reports whether two identities of fileID name the same file number, e.g. the inode,
+// regardless of the device they are on.
+func sameFileIndex(a, b string) bool {
+	_, indexA, okA := strings.Cut(a, ":")
+	_, indexB, okB := strings.Cut(b, ":")
+	return okA && okB && indexA == indexB
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+)
+
+// maxReplayBytes bounds how far back from its offset a file is read for a replay,
+// so a few very long lines cannot make a replay read a whole file.
+const maxReplayBytes = 1024 * 1024
+
+// replaySuffix marks the file paths of replayed content in the output.
+const replaySuffix = " (replay)"
+
+// handleReplaySignals asks the polling goroutine for a replay on each signal received.
+// Requests arriving while one is pending are merged into it.
+func (a *app) handleReplaySignals(signals <-chan os.Signal) {
+	for range signals {
+		select {
+		case a.replay <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// replayFiles writes the last --replay-lines complete lines read from each watched file,
+// under its path marked as a replay, in path order. Line transforms and --output-template apply,
+// but --include does not, so the replay shows the full recent context. Following resumes afterwards.
+// It is called by the polling goroutine, which owns the output.
+func (a *app) replayFiles() {
+	var paths []string
+	states := make(map[string]*fileState)
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		paths = append(paths, key.(string))
+		states[key.(string)] = value.(*fileState)
+		return true
+	})
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		state := states[path]
+		file, err := a.openFile(path)
+		if err != nil {
+			continue
+		}
+		data, err := lastLines(file, state.offset, a.replayLines, a.delim)
+		a.closeFile(file)
+		if err != nil {
+			a.logError("reading the last lines of %s for a replay: %v\n", path, err)
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		var out bytes.Buffer
+		for len(data) > 0 {
+			end := bytes.Index(data, a.delim) + len(a.delim)
+			a.outputLine(path, state, 0, -1, data[:end], &out)
+			data = data[end:]
+		}
+		shown := a.displayPath(path) + replaySuffix
+		if a.framing == framingLength {
+			a.writeFrames(shown, out.Bytes())
+		} else {
+			a.writeContent(shown, a.emittedFileID(path), out.Bytes())
+		}
+	}
+	// The content that follows gets a header again.
+	a.prevPath = ""
+	a.flushOutput()
+}
+
+// lastLines returns up to the last n complete lines of file before end, ending with delim,
+// reading it backwards. An incomplete line at end is left out, and at most maxReplayBytes are read.
+func lastLines(file io.ReaderAt, end int64, n int, delim []byte) ([]byte, error) {
+	const chunk = 4096
+	var buf []byte
+	pos := end
+	for pos > 0 && end-pos < maxReplayBytes && bytes.Count(buf, delim) <= n {
+		size := min(int64(chunk), pos)
+		pos -= size
+		b := make([]byte, size)
+		if _, err := file.ReadAt(b, pos); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(b, buf...)
+	}
+
+	// Leave out the incomplete line at the end, and at the start unless it starts the file.
+	i := bytes.LastIndex(buf, delim)
+	if i < 0 {
+		return nil, nil
+	}
+	buf = buf[:i+len(delim)]
+	start := 0
+	if pos > 0 {
+		start = bytes.Index(buf, delim) + len(delim)
+	}
+	lines := buf[start:]
+	for count := bytes.Count(lines, delim); count > n; count-- {
+		lines = lines[bytes.Index(lines, delim)+len(delim):]
+	}
+	return lines, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// replaySignals is empty, as this platform has no SIGUSR2; replays are not available.
+var replaySignals []os.Signal
@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// replaySignals are the signals asking for a replay of the last lines of the watched files.
+var replaySignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+)
+
+// reverseBlockSize is how much --reverse reads from a file at a time, going backward.
+const reverseBlockSize = 64 << 10
+
+// readLinesBackward calls yield with each line of r between start and end, including its delimiter,
+// from the last one to the first, reading blocks from the end. Lines may span any number of blocks.
+// A last line without a delimiter is yielded with one added, like a partial line flushed at the end.
+func readLinesBackward(r io.ReaderAt, start, end int64, delim []byte, yield func(at int64, line []byte)) error {
+	// buf holds the bytes from bufStart up to the end of the line being looked for.
+	var buf []byte
+	bufStart := end
+	last := true
+	for bufStart > start || len(buf) > 0 {
+		// Whether the line ends with a delimiter is only known once enough of it was read.
+		if len(buf) >= len(delim) || bufStart == start {
+			own := 0
+			if bytes.HasSuffix(buf, delim) {
+				own = len(delim)
+			}
+			i := bytes.LastIndex(buf[:len(buf)-own], delim)
+			if i >= 0 || bufStart == start {
+				s := i + len(delim)
+				if i < 0 {
+					s = 0
+				}
+				line := append([]byte(nil), buf[s:]...)
+				if own == 0 && last {
+					line = append(line, delim...)
+				}
+				yield(bufStart+int64(s), line)
+				buf, last = buf[:s], false
+				continue
+			}
+		}
+		n := min(int64(reverseBlockSize), bufStart-start)
+		block := make([]byte, n)
+		if _, err := r.ReadAt(block, bufStart-n); err != nil {
+			return err
+		}
+		buf = append(block, buf...)
+		bufStart -= n
+	}
+	return nil
+}
+
+// emitReversed emits the lines of a file between offset and end, newest first, for --reverse.
+// The lines go through the line processing as usual, only in reverse order.
+func (a *app) emitReversed(path string, state *fileState, file sourceFile, offset, end int64) error {
+	if a.stripBOM && offset == 0 {
+		head := make([]byte, len(utf8BOM))
+		if n, _ := file.ReadAt(head, 0); n == len(head) && bytes.Equal(head, utf8BOM) {
+			offset = int64(len(utf8BOM))
+		}
+	}
+	var out bytes.Buffer
+	err := readLinesBackward(file, offset, end, a.delim, func(at int64, line []byte) {
+		state.lines.lineAt = at
+		a.selectLine(path, state, line, &out)
+		if out.Len() >= reverseBlockSize {
+			a.emit(path, out.Bytes())
+			out.Reset()
+		}
+	})
+	if out.Len() > 0 {
+		a.emit(path, out.Bytes())
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLinesBackward(t *testing.T) {
+	long := strings.Repeat("x", reverseBlockSize+10) + "\n"
+	tests := []struct {
+		name    string
+		content string
+		start   int64
+		delim   string
+		want    []string
+		// at are the offsets of the lines yielded.
+		at []int64
+	}{
+		{"lines", "a\nbb\nccc\n", 0, "\n", []string{"ccc\n", "bb\n", "a\n"}, []int64{5, 2, 0}},
+		{"last line without newline", "a\nbb", 0, "\n", []string{"bb\n", "a\n"}, []int64{2, 0}},
+		{"blank lines", "\n\na\n", 0, "\n", []string{"a\n", "\n", "\n"}, []int64{2, 1, 0}},
+		{"from an offset", "a\nbb\nccc\n", 2, "\n", []string{"ccc\n", "bb\n"}, []int64{5, 2}},
+		{"delimiter of several bytes", "a\r\nb\r\n", 0, "\r\n", []string{"b\r\n", "a\r\n"}, []int64{3, 0}},
+		{"lines over blocks", "a\n" + long + "b\n", 0, "\n", []string{"b\n", long, "a\n"}, []int64{int64(2 + len(long)), 2, 0}},
+		{"empty", "", 0, "\n", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			var at []int64
+			err := readLinesBackward(strings.NewReader(tt.content), tt.start, int64(len(tt.content)), []byte(tt.delim), func(offset int64, line []byte) {
+				lines = append(lines, string(line))
+				at = append(at, offset)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(lines, tt.want) || !reflect.DeepEqual(at, tt.at) {
+				t.Errorf("lines %q at %v, want %q at %v", lines, at, tt.want, tt.at)
+			}
+		})
+	}
+}
+
+func TestReverseOutput(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "one\nerror two\nthree\nerror four\n", true)
+	tt := newTestTail(t, "--reverse", "--stop-at-eof", "--include", "error", "--after-context", "1", filepath.Join(dir, "*.log"))
+
+	// The lines go through the selection newest first, so the trailing context is the older line.
+	if got, want := tt.poll(), "error four\nthree\nerror two\none\n"; got != want {
+		t.Errorf("output %q, want %q", got, want)
+	}
+
+	// What is appended later is read forward.
+	writeFile(t, path, "error five\nerror six\n", false)
+	if got, want := tt.poll(), "error five\nerror six\n"; got != want {
+		t.Errorf("appended: output %q, want %q", got, want)
+	}
+}
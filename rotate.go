@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteSize is a flag.Value for sizes such as 100MB. The units are powers of 1024;
+// KiB, MiB, and GiB are accepted as well, and a plain number is in bytes.
+type byteSize int64
+
+// String returns the size in bytes.
+func (s *byteSize) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+// Set parses a size with an optional unit.
+func (s *byteSize) Set(v string) error {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	number, factor := strings.TrimSpace(v), int64(1)
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(number, u.suffix); ok {
+			number, factor = strings.TrimSpace(n), u.factor
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q, expected e.g. 100MB", v)
+	}
+	*s = byteSize(n * factor)
+	return nil
+}
+
+// rotatingFile is the --output file. Once it has reached --output-rotate-size, or is older than
+// --output-rotate-time, it is renamed with a timestamp suffix, optionally compressed, and a fresh
+// file is opened under the same path.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	maxAge  time.Duration
+	gzip    bool
+	// lines is true if rotation waits for the end of the current line, so no line is split across files.
+	lines bool
+
+	file   *os.File
+	size   int64
+	opened time.Time
+	// atLineStart is true if nothing was written yet, or the last byte written ended a line.
+	atLineStart bool
+}
+
+// openRotatingFile opens the output file at path, appending to it if it exists.
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, gzip, lines bool) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, gzip: gzip, lines: lines}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file at r.path for appending.
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening output file: %w", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("opening output file: %w", err)
+	}
+	r.file, r.size, r.opened, r.atLineStart = file, fi.Size(), time.Now(), true
+	return nil
+}
+
+// due reports whether the file is to be rotated.
+func (r *rotatingFile) due() bool {
+	return r.maxSize > 0 && r.size >= r.maxSize || r.maxAge > 0 && time.Since(r.opened) >= r.maxAge
+}
+
+// Write writes to the current file, rotating it first if it is due. When waiting for line ends,
+// a due rotation happens right after the next newline in p.
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if r.due() && (r.atLineStart || !r.lines) {
+			if err = r.rotate(); err != nil {
+				return n, err
+			}
+		}
+		chunk := p
+		if r.due() {
+			if i := bytes.IndexByte(p, '\n'); i >= 0 {
+				chunk = p[:i+1]
+			}
+		}
+		m, err := r.file.Write(chunk)
+		n += m
+		r.size += int64(m)
+		if m > 0 {
+			r.atLineStart = chunk[m-1] == '\n'
+		}
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// rotate renames the current file and opens a fresh one. The renamed file is compressed
+// in the background with --output-rotate-gzip, so the output does not stall meanwhile.
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		log.Printf("Error: closing output file %s: %v\n", r.path, err)
+	}
+	rotated := r.path + "." + time.Now().Format("20060102-150405")
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(rotated); os.IsNotExist(err) {
+			break
+		}
+		rotated = fmt.Sprintf("%s.%s.%d", r.path, time.Now().Format("20060102-150405"), i)
+	}
+	if err := os.Rename(r.path, rotated); err != nil {
+		log.Printf("Error: rotating output file %s: %v\n", r.path, err)
+	} else {
+		log.Printf("Info: Rotated output file to %s\n", rotated)
+		if r.gzip {
+			go gzipFile(rotated)
+		}
+	}
+	return r.open()
+}
+
+// Close closes the current file.
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+// gzipFile compresses a rotated output file to path.gz, and removes it once that succeeded.
+func gzipFile(path string) {
+	err := func() error {
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = in.Close() }()
+		out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+		zw := gzip.NewWriter(out)
+		if _, err = io.Copy(zw, in); err == nil {
+			err = zw.Close()
+		}
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(path + ".gz")
+		}
+		return err
+	}()
+	if err != nil {
+		log.Printf("Error: compressing rotated output file %s: %v\n", path, err)
+		return
+	}
+	_ = os.Remove(path)
+}
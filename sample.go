@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sampleFlag is the repeatable --sample flag. A value of 1/N, or just N, outputs every Nth line
+// of each file; PATTERN=1/N does so only for the files matched by that glob pattern, as given.
+type sampleFlag struct {
+	// every is the global ratio, and byPattern the ratios of single patterns. 0 means not set.
+	every     int
+	byPattern map[string]int
+}
+
+// String returns the ratios as given.
+func (f *sampleFlag) String() string {
+	var s []string
+	if f.every > 0 {
+		s = append(s, "1/"+strconv.Itoa(f.every))
+	}
+	for p, n := range f.byPattern {
+		s = append(s, p+"=1/"+strconv.Itoa(n))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+// Set parses a global or per-pattern ratio.
+func (f *sampleFlag) Set(v string) error {
+	pattern, ratio := "", v
+	if i := strings.LastIndex(v, "="); i >= 0 {
+		pattern, ratio = v[:i], v[i+1:]
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(ratio, "1/"))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid ratio %q, expected 1/N with N >= 1", ratio)
+	}
+	if pattern == "" {
+		f.every = n
+		return nil
+	}
+	if f.byPattern == nil {
+		f.byPattern = make(map[string]int)
+	}
+	f.byPattern[pattern] = n
+	return nil
+}
+
+// enabled reports whether any ratio is set.
+func (f *sampleFlag) enabled() bool {
+	return f.every > 1 || len(f.byPattern) > 0
+}
+
+// ratio returns N of the 1/N ratio for the files of a pattern. A pattern's own ratio takes
+// precedence over the global one.
+func (f *sampleFlag) ratio(pattern string) int {
+	if n, ok := f.byPattern[pattern]; ok {
+		return n
+	}
+	return f.every
+}
+
+// sampleKeep reports whether a line of a file is kept by --sample. Of every N lines offered,
+// the first is kept, deterministically per file; the others are skipped, but their offset is passed.
+// The lines kept are then sampled further while their file floods, see rateSampleKeep.
+func (a *app) sampleKeep(path string, state *fileState) bool {
+	n := a.sample.ratio(state.pattern)
+	if n <= 1 {
+		return a.rateSampleKeep(path, state)
+	}
+	ls := &state.lines
+	keep := ls.sampled%n == 0
+	ls.sampled++
+	return keep && a.rateSampleKeep(path, state)
+}
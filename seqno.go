@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// nextSeq returns the next --seqno sequence number, or 0 without --seqno.
+// Numbers are taken as records are written, so they increase in output order across all files.
+func (a *app) nextSeq() uint64 {
+	if !a.seqno {
+		return 0
+	}
+	return a.seq.Add(1)
+}
+
+// prefixSeq prefixes each line in data, which holds complete lines, with its sequence number
+// and a space. data is returned unchanged without --seqno.
+func (a *app) prefixSeq(data []byte) []byte {
+	if !a.seqno {
+		return data
+	}
+	var out bytes.Buffer
+	for len(data) > 0 {
+		line := data
+		if i := bytes.Index(data, a.delim); i >= 0 {
+			line, data = data[:i+len(a.delim)], data[i+len(a.delim):]
+		} else {
+			data = nil
+		}
+		out.WriteString(strconv.FormatUint(a.nextSeq(), 10))
+		out.WriteByte(' ')
+		out.Write(line)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// streamEvent is a single line sent to a --serve client.
+type streamEvent struct {
+	// Seq is only set by --format json with --seqno.
+	Seq  uint64 `json:"seq,omitempty"`
+	File string `json:"file"`
+	// FileID is only set by --format json with --emit-file-id.
+	FileID string    `json:"file_id,omitempty"`
+	Time   time.Time `json:"time"`
+	// Offset and Length are only set by --format json with --emit-offsets.
+	Offset *int64 `json:"offset,omitempty"`
+	Length int    `json:"length,omitempty"`
+	// LogTime is only set by --format json with --auto-timestamp, for lines with a timestamp.
+	LogTime *time.Time `json:"log_time,omitempty"`
+	Line    string     `json:"line"`
+}
+
+// subscriber is a connected --serve client.
+type subscriber struct {
+	// events is a bounded queue of events waiting to be sent to the client.
+	events chan streamEvent
+	// files is a list of glob patterns from the "file" query parameters.
+	// If it is empty, events of all files are sent.
+	files []string
+	// dropped counts the events dropped since the last one sent, because the client was too slow.
+	// It is guarded by the broadcaster's mutex.
+	dropped int
+}
+
+// wants reports whether the subscriber is interested in events of the given file.
+func (s *subscriber) wants(path string) bool {
+	if len(s.files) == 0 {
+		return true
+	}
+	for _, f := range s.files {
+		if f == path {
+			return true
+		}
+		if ok, _ := doublestar.Match(f, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcaster fans emitted lines out to all subscribers.
+// Publishing never blocks: if a subscriber's queue is full, the line is dropped for that subscriber only.
+type broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	bufferSize  int
+}
+
+// newBroadcaster creates a broadcaster whose subscribers buffer up to bufferSize events each.
+func newBroadcaster(bufferSize int) *broadcaster {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &broadcaster{
+		subscribers: make(map[*subscriber]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+// subscribe registers a new subscriber for the given file patterns.
+func (b *broadcaster) subscribe(files []string) *subscriber {
+	s := &subscriber{
+		events: make(chan streamEvent, b.bufferSize),
+		files:  files,
+	}
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// unsubscribe removes a subscriber. No events are queued for it afterward.
+func (b *broadcaster) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	delete(b.subscribers, s)
+	b.mu.Unlock()
+}
+
+// publish queues a line, without its delimiter, for every interested subscriber.
+// path is the path of the file as shown in the output, see displayPath, so --hash-paths applies.
+// Lines are published one by one as the line processing outputs them, so a line read in parts
+// is only published once it is complete, see publishLine.
+func (b *broadcaster) publish(path string, line []byte, t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		if !s.wants(path) {
+			continue
+		}
+		select {
+		case s.events <- streamEvent{File: path, Time: t, Line: string(line)}:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// publishLine publishes a line output by outputLine to the --serve clients, after the transforms
+// and before any --output-template or --format rendering it. Paused lines dropped with
+// --pause-mode drop are not published either.
+func (a *app) publishLine(path string, content []byte) {
+	if a.streams == nil || (a.pauseMode == pauseDrop && a.paused.Load()) {
+		return
+	}
+	a.streams.publish(a.displayPath(path), content, time.Now())
+}
+
+// takeDropped returns and resets the number of events dropped for a subscriber.
+func (b *broadcaster) takeDropped(s *subscriber) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
+
+// servePage is a minimal page that shows the event stream in a browser.
+const servePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>ftail</title></head>
+<body style="font-family: monospace; white-space: pre-wrap">
+<div id="log"></div>
+<script>
+const log = document.getElementById("log");
+const source = new EventSource("events" + location.search);
+source.addEventListener("line", e => {
+	const ev = JSON.parse(e.data);
+	const div = document.createElement("div");
+	div.textContent = ev.time + " " + ev.file + ": " + ev.line;
+	log.appendChild(div);
+	window.scrollTo(0, document.body.scrollHeight);
+});
+source.addEventListener("dropped", e => {
+	const div = document.createElement("div");
+	div.textContent = "--- " + e.data + " lines dropped ---";
+	log.appendChild(div);
+});
+</script>
+</body>
+</html>
+`
+
+// serve runs the HTTP server of --serve.
+// "/" serves a page showing the stream, and "/events" streams the lines as Server-Sent Events.
+// Both accept "file" query parameters with glob patterns to select the files to show.
+func (a *app) serve() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, servePage)
+	})
+	mux.HandleFunc("/events", a.handleEvents)
+
+	log.Printf("Info: Serving lines at http://%s/\n", a.serveAddr)
+	if err := http.ListenAndServe(a.serveAddr, mux); err != nil {
+		a.logError("serving %s: %v\n", a.serveAddr, err)
+	}
+}
+
+// handleEvents streams the emitted lines to a single client as Server-Sent Events.
+func (a *app) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	s := a.streams.subscribe(r.URL.Query()["file"])
+	defer a.streams.unsubscribe(s)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-s.events:
+			// Tell the client how many lines it missed before sending the next one.
+			if n := a.streams.takeDropped(s); n > 0 {
+				_, _ = fmt.Fprintf(w, "event: dropped\ndata: %d\n\n", n)
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				log.Printf("Error: encoding event: %v\n", err)
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "event: line\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
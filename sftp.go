@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// remoteSchemes are the URL schemes of patterns whose files are followed over SFTP.
+var remoteSchemes = []string{"sftp", "ssh", "scp"}
+
+// sftpTarget is the host the files of sftp:// patterns are on. The user and port are empty
+// for those of the ssh configuration. The zero value stands for the local filesystem.
+type sftpTarget struct {
+	user, host, port string
+}
+
+// String returns the target as given in a pattern, [user@]host[:port].
+func (t sftpTarget) String() string {
+	s := t.host
+	if t.port != "" {
+		s = net.JoinHostPort(t.host, t.port)
+	} else if strings.Contains(t.host, ":") {
+		s = "[" + t.host + "]"
+	}
+	if t.user != "" {
+		s = t.user + "@" + s
+	}
+	return s
+}
+
+// url returns the sftp:// URL of a path on the target.
+func (t sftpTarget) url(path string) string {
+	return "sftp://" + t.String() + filepath.ToSlash(path)
+}
+
+// splitRemotePatterns takes the target out of sftp://[user@]host[:port]/path patterns, and
+// returns it with the paths as the patterns. The patterns must either all be remote and on the
+// same target, or all be local, in which case they are returned as they are with the zero target.
+func splitRemotePatterns(patterns []string) (sftpTarget, []string, error) {
+	var target sftpTarget
+	paths := make([]string, 0, len(patterns))
+	local := 0
+	for _, p := range patterns {
+		scheme, rest, ok := strings.Cut(p, "://")
+		if !ok || !isRemoteScheme(scheme) {
+			local++
+			paths = append(paths, p)
+			continue
+		}
+		authority, path, _ := strings.Cut(rest, "/")
+		t, err := parseSFTPTarget(authority)
+		if err != nil {
+			return sftpTarget{}, nil, fmt.Errorf("pattern %s: %w", p, err)
+		}
+		if path == "" {
+			return sftpTarget{}, nil, fmt.Errorf("pattern %s has no path; give an absolute one, e.g. %s://%s/var/log/*.log", p, scheme, authority)
+		}
+		if target != (sftpTarget{}) && t != target {
+			return sftpTarget{}, nil, fmt.Errorf("patterns on %s and %s: all remote patterns must be on the same host; run one ftail for each", target, t)
+		}
+		target = t
+		paths = append(paths, "/"+path)
+	}
+	if target != (sftpTarget{}) && local > 0 {
+		return sftpTarget{}, nil, errors.New("remote and local patterns cannot be combined; run one ftail for each")
+	}
+	return target, paths, nil
+}
+
+// isRemoteScheme reports whether scheme is one of remoteSchemes.
+func isRemoteScheme(scheme string) bool {
+	for _, s := range remoteSchemes {
+		if scheme == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSFTPTarget parses the [user@]host[:port] of a pattern. IPv6 hosts are given in brackets.
+func parseSFTPTarget(authority string) (sftpTarget, error) {
+	var t sftpTarget
+	if user, hostPort, ok := strings.Cut(authority, "@"); ok {
+		t.user, authority = user, hostPort
+	}
+	t.host = authority
+	if strings.HasPrefix(authority, "[") && strings.HasSuffix(authority, "]") {
+		t.host = authority[1 : len(authority)-1]
+	} else if strings.Contains(authority, ":") {
+		host, port, err := net.SplitHostPort(authority)
+		if err != nil {
+			return sftpTarget{}, fmt.Errorf("invalid host %q: %w", authority, err)
+		}
+		t.host, t.port = host, port
+	}
+	if t.host == "" {
+		return sftpTarget{}, errors.New("no host given")
+	}
+	return t, nil
+}
+
+// sftpRetryDelay is the least time between attempts to connect after a failed one.
+const sftpRetryDelay = 5 * time.Second
+
+// sftpSource is the fileSource and discovery of the files on an SFTP server, for sftp:// patterns.
+// It is read-only, and not watchable, so new files are found by the periodic scan.
+// Files have no identity over SFTP, so a replaced file is only noticed if it is smaller than the offset.
+//
+// A connection lost is established again by the next call, at most every retryDelay.
+// Until then, calls fail with errSourceUnavailable, and the files are kept with their offsets.
+type sftpSource struct {
+	target sftpTarget
+	// dial opens a connection to the SFTP server, see dialSSH.
+	dial       func() (io.ReadWriteCloser, error)
+	retryDelay time.Duration
+
+	mu sync.Mutex
+	// client is the current connection, nil if there is none.
+	client *sftp.Client
+	// lastDial is the time of the last attempt to connect, and dialErr its error if it failed.
+	lastDial time.Time
+	dialErr  error
+	// lost is set when a connection is lost, so connecting again is logged.
+	lost bool
+}
+
+// newSFTPSource creates the source of the files on target, connecting with dial when first used.
+func newSFTPSource(target sftpTarget, dial func() (io.ReadWriteCloser, error)) *sftpSource {
+	return &sftpSource{target: target, dial: dial, retryDelay: sftpRetryDelay}
+}
+
+// connect returns the current connection, establishing one if there is none.
+func (s *sftpSource) connect() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+	if s.dialErr != nil && time.Since(s.lastDial) < s.retryDelay {
+		return nil, s.dialErr
+	}
+	s.lastDial = time.Now()
+	conn, err := s.dial()
+	var c *sftp.Client
+	if err == nil {
+		if c, err = sftp.NewClientPipe(conn, conn); err != nil {
+			_ = conn.Close()
+		}
+	}
+	if err != nil {
+		if s.dialErr == nil {
+			log.Printf("Warning: Connecting to %s over SFTP: %v; retrying every %v.\n", s.target, err, s.retryDelay)
+		}
+		s.dialErr = fmt.Errorf("%w: connecting to %s: %v", errSourceUnavailable, s.target, err)
+		return nil, s.dialErr
+	}
+	if s.lost || s.dialErr != nil {
+		log.Printf("Info: Connected to %s over SFTP again.\n", s.target)
+	}
+	s.client, s.dialErr, s.lost = c, nil, false
+	return c, nil
+}
+
+// drop closes a connection that failed with err, so the next call connects again.
+func (s *sftpSource) drop(c *sftp.Client, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = c.Close()
+	if s.client != c {
+		return
+	}
+	s.client, s.lost = nil, true
+	log.Printf("Warning: Lost the SFTP connection to %s: %v; reconnecting.\n", s.target, err)
+}
+
+// close closes the current connection, if any.
+func (s *sftpSource) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		_ = s.client.Close()
+		s.client = nil
+	}
+}
+
+// check returns the error of op on path over c. Errors the server returned for the file are given
+// the path, so they read like those of the local filesystem, and io.EOF is returned as is. Any other
+// error means the connection failed: it is dropped, and the error wraps errSourceUnavailable.
+func (s *sftpSource) check(c *sftp.Client, op, path string, err error) error {
+	var status *sftp.StatusError
+	switch {
+	case err == nil || err == io.EOF:
+		return err
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission), errors.Is(err, fs.ErrInvalid),
+		errors.Is(err, fs.ErrClosed), errors.As(err, &status):
+		return &fs.PathError{Op: op, Path: path, Err: err}
+	}
+	s.drop(c, err)
+	return fmt.Errorf("%w: %s %s: %v", errSourceUnavailable, op, path, err)
+}
+
+// Stat implements fileSource.
+func (s *sftpSource) Stat(path string) (os.FileInfo, error) {
+	c, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	fi, err := c.Stat(filepath.ToSlash(path))
+	if err = s.check(c, "stat", path, err); err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// Open implements fileSource. The file can only be read.
+func (s *sftpSource) Open(path string) (sourceFile, error) {
+	f, err := s.open(path)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at path for reading.
+func (s *sftpSource) open(path string) (*sftpFile, error) {
+	c, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	f, err := c.Open(filepath.ToSlash(path))
+	if err = s.check(c, "open", path, err); err != nil {
+		return nil, err
+	}
+	return &sftpFile{File: f, source: s, client: c, path: path}, nil
+}
+
+// Identity implements fileSource. SFTP has no file identities, see sftpSource.
+func (s *sftpSource) Identity(string, os.FileInfo) (string, bool) {
+	return "", false
+}
+
+// DirFS implements discovery.
+func (s *sftpSource) DirFS(dir string) fs.FS {
+	return sftpFS{source: s, dir: dir}
+}
+
+// EvalSymlinks implements discovery. Like filepath.EvalSymlinks, it fails if the path does not exist.
+func (s *sftpSource) EvalSymlinks(path string) (string, error) {
+	c, err := s.connect()
+	if err != nil {
+		return "", err
+	}
+	resolved, err := c.RealPath(filepath.ToSlash(path))
+	if err = s.check(c, "realpath", path, err); err != nil {
+		return "", err
+	}
+	// Servers may resolve paths that do not exist.
+	resolved = filepath.FromSlash(resolved)
+	if _, err := s.Stat(resolved); err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// Watchable implements discovery. There are no notifications over SFTP.
+func (s *sftpSource) Watchable() bool {
+	return false
+}
+
+// readDir returns the entries of the directory at path, sorted by name.
+func (s *sftpSource) readDir(path string) ([]fs.DirEntry, error) {
+	c, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	infos, err := c.ReadDir(filepath.ToSlash(path))
+	if err = s.check(c, "readdir", path, err); err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// sftpFS is the filesystem of a remote directory, as walked by globWalk.
+type sftpFS struct {
+	source *sftpSource
+	dir    string
+}
+
+// path returns the remote path of a name of the FS, or an error if the name is invalid.
+func (f sftpFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(f.dir, filepath.FromSlash(name)), nil
+}
+
+// Open implements fs.FS.
+func (f sftpFS) Open(name string) (fs.File, error) {
+	path, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.source.open(path)
+}
+
+// Stat implements fs.StatFS.
+func (f sftpFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.source.Stat(path)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (f sftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.source.readDir(path)
+}
+
+// sftpFile is a remote file opened for reading, with the errors of the source, see sftpSource.check.
+// It is bound to the connection it was opened on, so it fails once that is lost.
+type sftpFile struct {
+	*sftp.File
+	source *sftpSource
+	client *sftp.Client
+	path   string
+}
+
+// Read implements io.Reader.
+func (f *sftpFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, f.source.check(f.client, "read", f.path, err)
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *sftpFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	return n, f.source.check(f.client, "read", f.path, err)
+}
+
+// Seek implements io.Seeker.
+func (f *sftpFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.File.Seek(offset, whence)
+	return n, f.source.check(f.client, "seek", f.path, err)
+}
+
+// Stat returns the current information of the file.
+func (f *sftpFile) Stat() (os.FileInfo, error) {
+	fi, err := f.File.Stat()
+	if err = f.source.check(f.client, "fstat", f.path, err); err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// Close implements io.Closer.
+func (f *sftpFile) Close() error {
+	return f.source.check(f.client, "close", f.path, f.File.Close())
+}
+
+// dialSSH returns a dial function of sftpSource running command, e.g. ssh, with the arguments
+// requesting the sftp subsystem of target. Authentication and host keys are those of ssh. Its
+// options come first, so they override the BatchMode and keepalive ones added after them.
+func dialSSH(command string, target sftpTarget) func() (io.ReadWriteCloser, error) {
+	return func() (io.ReadWriteCloser, error) {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			return nil, errors.New("empty --ssh-command")
+		}
+		sshArgs := append(fields[1:], "-o", "BatchMode=yes", "-o", "ServerAliveInterval=15")
+		if target.user != "" {
+			sshArgs = append(sshArgs, "-l", target.user)
+		}
+		if target.port != "" {
+			sshArgs = append(sshArgs, "-p", target.port)
+		}
+		cmd := exec.Command(fields[0], append(sshArgs, "-s", target.host, "sftp")...)
+		cmd.Stderr = log.Writer()
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &sshConn{Reader: stdout, WriteCloser: stdin, cmd: cmd}, nil
+	}
+}
+
+// sshConn is a connection through the standard input and output of an ssh process.
+type sshConn struct {
+	io.Reader
+	io.WriteCloser
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+// Close ends the ssh process.
+func (c *sshConn) Close() error {
+	c.once.Do(func() {
+		_ = c.WriteCloser.Close()
+		_ = c.cmd.Process.Kill()
+		_ = c.cmd.Wait()
+	})
+	return nil
+}
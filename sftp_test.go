@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+func TestSplitRemotePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		target   sftpTarget
+		want     []string
+		wantErr  bool
+	}{
+		{"local", []string{"/var/log/*.log"}, sftpTarget{}, []string{"/var/log/*.log"}, false},
+		{"user", []string{"sftp://alice@logs/var/log/**/*.log"}, sftpTarget{user: "alice", host: "logs"}, []string{"/var/log/**/*.log"}, false},
+		{"port", []string{"ssh://logs:2222/var/log/app.log"}, sftpTarget{host: "logs", port: "2222"}, []string{"/var/log/app.log"}, false},
+		{"IPv6", []string{"scp://[::1]/a.log", "sftp://[::1]/b.log"}, sftpTarget{host: "::1"}, []string{"/a.log", "/b.log"}, false},
+		{"IPv6 with port", []string{"sftp://[::1]:22/a.log"}, sftpTarget{host: "::1", port: "22"}, []string{"/a.log"}, false},
+		{"other hosts", []string{"sftp://a/x.log", "sftp://b/x.log"}, sftpTarget{}, nil, true},
+		{"mixed with local", []string{"sftp://a/x.log", "/x.log"}, sftpTarget{}, nil, true},
+		{"no path", []string{"sftp://a/"}, sftpTarget{}, nil, true},
+		{"no host", []string{"sftp:///var/log/x.log"}, sftpTarget{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, paths, err := splitRemotePatterns(tt.patterns)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want one: %v", err, tt.wantErr)
+			}
+			if target != tt.target || (err == nil && !reflect.DeepEqual(paths, tt.want)) {
+				t.Errorf("splitRemotePatterns(%q) = %+v, %q; want %+v, %q", tt.patterns, target, paths, tt.target, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteDisplayPath(t *testing.T) {
+	for _, tt := range []struct {
+		target sftpTarget
+		want   string
+	}{
+		{sftpTarget{user: "alice", host: "logs"}, "sftp://alice@logs/var/log/app.log"},
+		{sftpTarget{host: "::1", port: "2222"}, "sftp://[::1]:2222/var/log/app.log"},
+		{sftpTarget{host: "::1"}, "sftp://[::1]/var/log/app.log"},
+	} {
+		a := localApp(&args{remote: tt.target})
+		if got := a.displayPath("/var/log/app.log"); got != tt.want {
+			t.Errorf("displayPath on %+v = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRemotePatternsValidate(t *testing.T) {
+	cfg, err := parseArgs("ftail", []string{"sftp://alice@logs/var/log/*.log"})
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (sftpTarget{user: "alice", host: "logs"}); cfg.remote != want || !reflect.DeepEqual(cfg.patterns, []string{"/var/log/*.log"}) {
+		t.Errorf("remote %+v, patterns %q; want %+v, [/var/log/*.log]", cfg.remote, cfg.patterns, want)
+	}
+
+	cfg, err = parseArgs("ftail", []string{"--regex", "--root", "/var/log", "sftp://logs/var/log/.*"})
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err == nil {
+		t.Error("sftp:// patterns accepted with --regex")
+	}
+}
+
+// sftpTestServer connects sftpSource to serveSFTP over in-memory pipes. It can drop the
+// connections, and refuse new ones while it is down.
+type sftpTestServer struct {
+	mu    sync.Mutex
+	down  bool
+	conns []net.Conn
+}
+
+// dial is the dial function of sftpSource.
+func (s *sftpTestServer) dial() (io.ReadWriteCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	s.conns = append(s.conns, server)
+	go serveSFTP(server)
+	return client, nil
+}
+
+// setDown drops the connections and refuses new ones if down is true, and accepts them again otherwise.
+func (s *sftpTestServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+	if down {
+		for _, c := range s.conns {
+			_ = c.Close()
+		}
+		s.conns = nil
+	}
+}
+
+// serveSFTP serves the local filesystem read-only over conn, until conn is closed.
+func serveSFTP(conn io.ReadWriteCloser) {
+	server, err := sftp.NewServer(conn, sftp.ReadOnly())
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	_ = server.Serve()
+	_ = server.Close()
+}
+
+func TestSFTPSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	writeFile(t, path, "old\n", true)
+	server := &sftpTestServer{}
+	t.Cleanup(func() { server.setDown(true) })
+	src := newSFTPSource(sftpTarget{host: "logs"}, server.dial)
+	src.retryDelay = 0
+	tt := newSourceTail(t, src, filepath.Join(dir, "*.log"))
+
+	writeFile(t, path, "one\n", false)
+	if got, want := tt.poll(), "one\n"; got != want {
+		t.Fatalf("output %q, want %q\nlogs:\n%s", got, want, tt.logs.String())
+	}
+
+	// Content longer than a read request, of at most 32 KiB, is read with several.
+	long := strings.Repeat("x", 100*1024) + "\n"
+	writeFile(t, path, long, false)
+	if got := tt.poll(); got != long {
+		t.Errorf("output %d bytes, want %d", len(got), len(long))
+	}
+
+	// While the server cannot be reached, the file stays watched and nothing is output.
+	server.setDown(true)
+	writeFile(t, path, "two\n", false)
+	tt.scan()
+	if got := tt.poll(); got != "" {
+		t.Errorf("output %q while disconnected", got)
+	}
+	if !tt.watched()[path] {
+		t.Errorf("%s not watched while disconnected", path)
+	}
+	if strings.Contains(tt.logs.String(), "Error:") {
+		t.Errorf("errors logged while disconnected:\n%s", tt.logs.String())
+	}
+
+	// Once it can, reading continues at the offset.
+	server.setDown(false)
+	if got, want := tt.poll(), "two\n"; got != want {
+		t.Errorf("output %q after reconnecting, want %q\nlogs:\n%s", got, want, tt.logs.String())
+	}
+	for _, msg := range []string{"Warning: Lost the SFTP connection to logs", "Info: Connected to logs over SFTP again."} {
+		if !strings.Contains(tt.logs.String(), msg) {
+			t.Errorf("%q not logged; logs:\n%s", msg, tt.logs.String())
+		}
+	}
+
+	// The missing file is noticed, and no longer watched.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	tt.poll()
+	if tt.watched()[path] {
+		t.Errorf("%s still watched after its removal", path)
+	}
+}
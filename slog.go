@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Formats of --log-format.
+const (
+	logFormatText     = "text"
+	logFormatSlogJSON = "slog-json"
+)
+
+// validLogFormat checks a --log-format.
+func validLogFormat(format string) error {
+	if format != logFormatText && format != logFormatSlogJSON {
+		return fmt.Errorf("--log-format must be %s or %s, got %q", logFormatText, logFormatSlogJSON, format)
+	}
+	return nil
+}
+
+// slogLevels maps the level prefixes of the log messages to slog levels.
+var slogLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// slogWriter is the destination of the standard logger with --log-format slog-json. It turns each
+// message into a record of a slog logger, with the level of its prefix, so ftail's own diagnostics
+// can be collected like those of other slog-based services, apart from the content.
+type slogWriter struct {
+	logger *slog.Logger
+}
+
+// newSlogWriter creates a writer logging JSON records to w.
+func newSlogWriter(w io.Writer) slogWriter {
+	return slogWriter{logger: slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+}
+
+// Write implements io.Writer, taking one log message per call as the standard logger does.
+func (s slogWriter) Write(p []byte) (int, error) {
+	rec := parseLogMessage(p)
+	s.logger.Log(context.Background(), slogLevels[rec.Level], rec.Msg)
+	return len(p), nil
+}
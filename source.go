@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// fileSource is where the watched files are read from. The offset, truncation, and replacement
+// handling of pollFile and addToWatchFile only goes through it, so other backends, such as
+// archives or remote hosts, can reuse that logic by implementing it. localSource is the default.
+// The files are found through a discovery, see there. Config, manifest, and output files are always local.
+type fileSource interface {
+	// Stat returns information about the file at path, with errors satisfying os.IsNotExist if it is gone.
+	Stat(path string) (os.FileInfo, error)
+	// Open opens the file at path for reading.
+	Open(path string) (sourceFile, error)
+	// Identity returns an identity of the file that changes when another file replaces it
+	// under the same path. ok is false if the source cannot tell.
+	Identity(path string, fi os.FileInfo) (id string, ok bool)
+}
+
+// sourceFile is a file opened by a fileSource. Reads may start at any offset.
+type sourceFile interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// errSourceUnavailable is wrapped by the errors of a source that cannot be reached for now, e.g.
+// because its connection was lost. They say nothing about the files, so the files are kept with
+// their offsets, and the errors are not logged for each of them; the source logs its state itself.
+var errSourceUnavailable = errors.New("source unavailable")
+
+// discovery is how the files matching the patterns are found. globWalk walks the directories
+// it returns, and the paths found are resolved through it, as are the paths checked by globMatch.
+// A discovery that is not watchable is only scanned periodically, as with --poll-only.
+type discovery interface {
+	// DirFS returns the filesystem of the directory dir, as walked for the patterns based there.
+	DirFS(dir string) fs.FS
+	// EvalSymlinks returns path with its symlinks resolved, like filepath.EvalSymlinks.
+	EvalSymlinks(path string) (string, error)
+	// Watchable reports whether the directory watcher can notice new files in its directories.
+	Watchable() bool
+}
+
+// localSource is the fileSource and discovery of the local filesystem.
+type localSource struct{}
+
+// Stat implements fileSource.
+func (localSource) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// Open implements fileSource.
+func (localSource) Open(path string) (sourceFile, error) {
+	file, err := openShared(path)
+	if err != nil {
+		// Avoid returning a non-nil interface holding a nil *os.File.
+		return nil, err
+	}
+	return file, nil
+}
+
+// Identity implements fileSource, see fileID.
+func (localSource) Identity(path string, fi os.FileInfo) (string, bool) {
+	return fileID(path, fi)
+}
+
+// DirFS implements discovery.
+func (localSource) DirFS(dir string) fs.FS {
+	return os.DirFS(dir)
+}
+
+// EvalSymlinks implements discovery.
+func (localSource) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
+// Watchable implements discovery.
+func (localSource) Watchable() bool {
+	return true
+}
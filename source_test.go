@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// memSource is an in-memory fileSource and discovery. Nothing of it exists on the local
+// filesystem, so whatever ftail outputs for its files came through the interfaces.
+// A file opened reads the content it had when it was opened.
+type memSource struct {
+	mu    sync.Mutex
+	files map[string]*memEntry
+	// created counts the files created, and gives each its identity.
+	created int
+}
+
+// memEntry is a file of a memSource.
+type memEntry struct {
+	data    []byte
+	id      int
+	modTime time.Time
+}
+
+func newMemSource() *memSource {
+	return &memSource{files: make(map[string]*memEntry)}
+}
+
+// write appends data to the file at path, creating it if needed.
+func (s *memSource) write(path, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.files[path]
+	if !ok {
+		s.created++
+		e = &memEntry{id: s.created}
+		s.files[path] = e
+	}
+	e.data = append(e.data, data...)
+	e.modTime = time.Now()
+}
+
+// truncate empties the file at path.
+func (s *memSource) truncate(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[path].data = nil
+	s.files[path].modTime = time.Now()
+}
+
+// remove removes the file at path.
+func (s *memSource) remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+}
+
+// Stat implements fileSource. Directories exist as long as they have files.
+func (s *memSource) Stat(name string) (os.FileInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.files[name]; ok {
+		return memInfo{name: path.Base(name), size: int64(len(e.data)), modTime: e.modTime}, nil
+	}
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for p := range s.files {
+		if strings.HasPrefix(p, prefix) {
+			return memInfo{name: path.Base(name), dir: true}, nil
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Open implements fileSource.
+func (s *memSource) Open(name string) (sourceFile, error) {
+	fi, err := s.Stat(name)
+	if err == nil && fi.IsDir() {
+		err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return memFile{Reader: bytes.NewReader(bytes.Clone(s.files[name].data)), info: fi}, nil
+}
+
+// Identity implements fileSource.
+func (s *memSource) Identity(name string, _ os.FileInfo) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.files[name]; ok {
+		return strconv.Itoa(e.id), true
+	}
+	return "", false
+}
+
+// DirFS implements discovery, with the files below dir at the time of the call.
+func (s *memSource) DirFS(dir string) fs.FS {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fsys := make(fstest.MapFS)
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for p, e := range s.files {
+		if rel, ok := strings.CutPrefix(p, prefix); ok {
+			fsys[rel] = &fstest.MapFile{Data: bytes.Clone(e.data), ModTime: e.modTime}
+		}
+	}
+	return fsys
+}
+
+// EvalSymlinks implements discovery. There are no symlinks.
+func (s *memSource) EvalSymlinks(name string) (string, error) {
+	if _, err := s.Stat(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Watchable implements discovery. New files are only found by scanning.
+func (s *memSource) Watchable() bool {
+	return false
+}
+
+// memFile is a file opened from a memSource.
+type memFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+// Close implements io.Closer.
+func (memFile) Close() error { return nil }
+
+// Stat returns the information of the file when it was opened.
+func (f memFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+// memInfo is the os.FileInfo of a memSource file or directory.
+type memInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() any           { return nil }
+
+func (i memInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+func TestMemSource(t *testing.T) {
+	const (
+		dir  = "/mem/logs"
+		path = dir + "/app.log"
+		next = dir + "/next.log"
+	)
+	type step struct {
+		// do changes the files of the source.
+		do   func(s *memSource)
+		want string
+		// watched are the files watched after the step.
+		watched []string
+	}
+	appendData := func(p, data string) func(*memSource) {
+		return func(s *memSource) { s.write(p, data) }
+	}
+
+	tests := []struct {
+		name string
+		// args are given before the pattern.
+		args    []string
+		pattern string
+		steps   []step
+	}{
+		{
+			name:    "grow",
+			pattern: dir + "/*.log",
+			steps: []step{
+				{appendData(path, "one\n"), "one\n", []string{path}},
+				{appendData(path, "two\nthree\n"), "two\nthree\n", []string{path}},
+			},
+		},
+		{
+			name:    "from start",
+			args:    []string{"--from-start"},
+			pattern: dir + "/*.log",
+			steps: []step{
+				{func(*memSource) {}, "old\n", []string{path}},
+			},
+		},
+		{
+			name:    "truncate",
+			pattern: dir + "/*.log",
+			steps: []step{
+				{appendData(path, "one\n"), "one\n", []string{path}},
+				{func(s *memSource) { s.truncate(path); s.write(path, "new\n") }, "new\n", []string{path}},
+			},
+		},
+		{
+			name:    "replace",
+			pattern: dir + "/*.log",
+			steps: []step{
+				// The new file is larger than the offset, so only its identity tells it apart.
+				{func(s *memSource) { s.remove(path); s.write(path, "a new, larger file\n") }, "a new, larger file\n", []string{path}},
+			},
+		},
+		{
+			name:    "new file found by the scan",
+			pattern: dir + "/*.log",
+			steps: []step{
+				// Files found after startup are followed from their end, like at startup.
+				{appendData(next, ""), "", []string{path, next}},
+				{appendData(next, "first\n"), "first\n", []string{path, next}},
+			},
+		},
+		{
+			name:    "remove",
+			pattern: dir + "/*.log",
+			steps: []step{
+				{func(s *memSource) { s.remove(path) }, "", nil},
+			},
+		},
+		{
+			name:    "directory pattern",
+			pattern: dir,
+			steps: []step{
+				{appendData(path, "one\n"), "one\n", []string{path}},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := newMemSource()
+			src.write(path, "old\n")
+			tt := newSourceTail(t, src, append(tc.args, tc.pattern)...)
+			for i, s := range tc.steps {
+				s.do(src)
+				tt.scan()
+				if got := tt.poll(); got != s.want {
+					t.Errorf("step %d: output %q, want %q\nlogs:\n%s", i, got, s.want, tt.logs.String())
+				}
+				watched := tt.watched()
+				if len(watched) != len(s.watched) {
+					t.Errorf("step %d: watching %v, want %v", i, watched, s.watched)
+				}
+				for _, p := range s.watched {
+					if !watched[p] {
+						t.Errorf("step %d: %s not watched", i, p)
+					}
+				}
+			}
+		})
+	}
+}
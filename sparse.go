@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// errSparseUnsupported is returned by dataEnd on platforms without SEEK_DATA/SEEK_HOLE.
+var errSparseUnsupported = errors.New("sparse file probing is not supported on this platform")
+
+// maxZeroTrim bounds how far dataEnd scans backward over trailing NUL bytes,
+// so a large preallocated region of zeros does not have to be read in full.
+const maxZeroTrim = 1 << 20
+
+// dataSize returns the number of bytes of a file that hold written data.
+// Without --sparse-probe it is simply the size reported by Stat. With it, holes and
+// trailing NUL bytes of preallocated files are excluded, so reads do not start past
+// the real data or emit the zero-filled tail. If probing fails, the reported size is used.
+// Probing moves the file position, so callers must seek afterward. Only local files can be probed.
+func (a *app) dataSize(file sourceFile, size int64) int64 {
+	local, ok := file.(*os.File)
+	if !a.sparseProbe || !ok {
+		return size
+	}
+
+	end, err := dataEnd(local, size)
+	if err != nil {
+		a.sparseFallback.Do(func() {
+			log.Printf("Info: --sparse-probe unavailable, using file sizes as reported: %v\n", err)
+		})
+		return size
+	}
+	return end
+}
+
+// trimZeros moves end backward over NUL bytes, scanning at most maxZeroTrim bytes.
+func trimZeros(file *os.File, end int64) (int64, error) {
+	buf := make([]byte, 64*1024)
+	limit := end - maxZeroTrim
+	for end > 0 && end > limit {
+		n := int64(len(buf))
+		if end < n {
+			n = end
+		}
+		chunk := buf[:n]
+		if _, err := file.ReadAt(chunk, end-n); err != nil && !errors.Is(err, io.EOF) {
+			return end, err
+		}
+		trimmed := bytes.TrimRight(chunk, "\x00")
+		end -= n - int64(len(trimmed))
+		if len(trimmed) > 0 {
+			break
+		}
+	}
+	return end, nil
+}
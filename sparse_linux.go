@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dataEnd returns the end of the last data region of a file, based on SEEK_DATA and SEEK_HOLE,
+// with any trailing NUL bytes of that region excluded.
+func dataEnd(file *os.File, size int64) (int64, error) {
+	end := int64(0)
+	for pos := int64(0); pos < size; {
+		start, err := file.Seek(pos, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// There is no more data after pos.
+			break
+		}
+		if err != nil {
+			return size, err
+		}
+		hole, err := file.Seek(start, unix.SEEK_HOLE)
+		if err != nil {
+			return size, err
+		}
+		end, pos = hole, hole
+	}
+	if end > size {
+		end = size
+	}
+	return trimZeros(file, end)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// dataEnd is not supported on this platform, so the reported size is used.
+func dataEnd(_ *os.File, size int64) (int64, error) {
+	return size, errSparseUnsupported
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// deviceReadLimit bounds what a single poll reads from a device file with --read-devices.
+const deviceReadLimit = 64 * 1024
+
+// specialFileReason returns why a file that is neither regular nor a directory is not tailed,
+// or "" if it can be. Reading a device or a named pipe to the end may block forever or never end,
+// so they are skipped; device files are read in a bounded way if --read-devices is given.
+func (a *app) specialFileReason(fi os.FileInfo) string {
+	mode := fi.Mode()
+	switch {
+	case mode&os.ModeDevice != 0 && !a.readDevices:
+		return "device file"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	default:
+		return ""
+	}
+}
+
+// isDeviceFile reports whether fi describes a block or character device.
+func isDeviceFile(fi os.FileInfo) bool {
+	return fi.Mode()&os.ModeDevice != 0
+}
+
+// readDevice reads what a device file has available, at most deviceReadLimit bytes.
+// Devices have no size or offset to follow, so a single read is done, which gives up
+// after a poll interval if the device supports deadlines.
+func (a *app) readDevice(path string, state *fileState, file sourceFile) []byte {
+	if local, ok := file.(*os.File); ok {
+		_ = local.SetReadDeadline(time.Now().Add(a.pollInterval))
+	}
+	buf := make([]byte, deviceReadLimit)
+	n, err := file.Read(buf)
+	if err != nil && n == 0 && !errors.Is(err, os.ErrDeadlineExceeded) {
+		a.logError("reading device %s (pattern %s): %v\n", path, state.pattern, err)
+	}
+	return buf[:n]
+}
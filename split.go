@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSplitOutputMaxOpen is the default --split-output-max-open.
+const defaultSplitOutputMaxOpen = 64
+
+// splitFile is an open file of --split-output.
+type splitFile struct {
+	f *os.File
+	w *bufio.Writer
+	// used is when the file was last written to, counted in writes, for closing the least recently used.
+	used uint64
+}
+
+// splitOutputs writes the content of each watched file to a file of its own under the --split-output
+// directory. At most maxOpen of them are open at once; the least recently written is closed to open
+// another, and appended to when it is written to again. It is only accessed from the polling goroutine.
+type splitOutputs struct {
+	dir     string
+	maxOpen int
+	open    map[string]*splitFile
+	writes  uint64
+}
+
+// newSplitOutputs creates the outputs of --split-output under dir.
+func newSplitOutputs(dir string, maxOpen int) *splitOutputs {
+	return &splitOutputs{dir: dir, maxOpen: maxOpen, open: make(map[string]*splitFile)}
+}
+
+// splitOutputPath returns the file under the --split-output directory for a watched file: its path
+// relative to the base directory of the glob pattern that matched it, so the structure under the
+// base is kept, or its whole path if it is outside the base, e.g. as the target of a symlink.
+func (a *app) splitOutputPath(path string, state *fileState) string {
+	if g, ok := a.patternByRaw(state.pattern); ok {
+		if rel, err := filepath.Rel(g.absBase, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(a.split.dir, rel)
+		}
+	}
+	return filepath.Join(a.split.dir, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// writer returns the buffered writer of the output file at path, opening it if needed.
+func (s *splitOutputs) writer(path string) (*bufio.Writer, error) {
+	s.writes++
+	if sf := s.open[path]; sf != nil {
+		sf.used = s.writes
+		return sf.w, nil
+	}
+	if len(s.open) >= s.maxOpen {
+		s.closeLeastRecent()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	sf := &splitFile{f: f, w: newOutput(f), used: s.writes}
+	s.open[path] = sf
+	return sf.w, nil
+}
+
+// closeLeastRecent closes the output file written to least recently.
+func (s *splitOutputs) closeLeastRecent() {
+	var oldest string
+	for path, sf := range s.open {
+		if oldest == "" || sf.used < s.open[oldest].used {
+			oldest = path
+		}
+	}
+	s.closeFile(oldest)
+}
+
+// closeFile flushes and closes an open output file.
+func (s *splitOutputs) closeFile(path string) {
+	sf := s.open[path]
+	_ = sf.w.Flush()
+	_ = sf.f.Close()
+	delete(s.open, path)
+}
+
+// flush writes out what is buffered for the open output files.
+func (s *splitOutputs) flush() {
+	for _, sf := range s.open {
+		_ = sf.w.Flush()
+	}
+}
+
+// closeAll closes all open output files, e.g. before exiting.
+func (s *splitOutputs) closeAll() {
+	for path := range s.open {
+		s.closeFile(path)
+	}
+}
+
+// writeSplit writes content of a file to its --split-output file rather than the output, formatted
+// as it would be there, but without headers, as the file only holds the content of one file.
+func (a *app) writeSplit(path, shown string, data []byte) {
+	state, ok := a.watchedFiles.Load(path)
+	if !ok {
+		return
+	}
+	out := a.splitOutputPath(path, state.(*fileState))
+	w, err := a.split.writer(out)
+	if err != nil {
+		a.logError("opening --split-output file %s for %s: %v\n", out, path, err)
+		return
+	}
+	// The writes of writeContent go to the output file of the watched file.
+	saved := a.out
+	a.out = w
+	defer func() { a.out = saved }()
+	a.writeContent(shown, a.emittedFileID(path), data)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// stateRotationGrace is how long a file must have been gone before its --state-file entry may be
+// dropped, so the entry survives a rotation removing the file for a moment before it is created again.
+const stateRotationGrace = time.Minute
+
+// stateEntry is the saved state of a file in the --state-file.
+type stateEntry struct {
+	Offset int64 `json:"offset"`
+	// ID is the identity of the file, see fileSource.Identity, empty if the source cannot tell.
+	ID string `json:"id,omitempty"`
+	// Seen is when the file was last watched.
+	Seen time.Time `json:"seen"`
+	// Missing is when the file was first found gone since, zero while it exists.
+	Missing time.Time `json:"missing,omitzero"`
+}
+
+// stateContent is the content of the --state-file.
+type stateContent struct {
+	// Seq is the last --seqno sequence number taken, so a resumed run numbers the records after it.
+	Seq   uint64                 `json:"seq,omitempty"`
+	Files map[string]*stateEntry `json:"files"`
+}
+
+// stateStore holds the entries of the --state-file. It is saved by the polling goroutine, and
+// looked up when files are added.
+type stateStore struct {
+	path string
+	// ttl is --state-ttl, 0 to keep the entries of files that are gone.
+	ttl time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	entries map[string]*stateEntry
+}
+
+// loadState reads the --state-file at path. A file that does not exist yet has no entries.
+func loadState(path string, ttl time.Duration) (*stateStore, error) {
+	s := &stateStore{path: path, ttl: ttl, entries: make(map[string]*stateEntry)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	var content stateContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("reading state file %s: %w", path, err)
+	}
+	if content.Files != nil {
+		s.entries = content.Files
+	}
+	s.seq = content.Seq
+	return s, nil
+}
+
+// openState loads the --state-file, if given. One that cannot be read stops the startup, rather
+// than being replaced by the first save, so its offsets are not lost before it can be looked at.
+func (a *app) openState() error {
+	if a.stateFile == "" {
+		return nil
+	}
+	state, err := loadState(a.stateFile, a.stateTTL)
+	if err != nil {
+		return fmt.Errorf("%w; fix or remove it to start without the saved offsets", err)
+	}
+	a.state, a.lastStateSave = state, a.startTime
+	if a.seqno {
+		a.seq.Store(state.seq)
+	}
+	return nil
+}
+
+// offset returns the saved offset of the file at path, if it is the same file as when it was saved
+// and has not shrunk below the offset since.
+func (s *stateStore) offset(path, id string, size int64) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[path]
+	if !ok || e.ID != id || e.Offset > size {
+		return 0, false
+	}
+	return e.Offset, true
+}
+
+// stateOffset returns the offset of a file being added from the --state-file, see stateStore.offset.
+func (a *app) stateOffset(path string, fi os.FileInfo) (int64, bool) {
+	if a.state == nil {
+		return 0, false
+	}
+	id, _ := a.source.Identity(path, fi)
+	offset, ok := a.state.offset(path, id, fi.Size())
+	if ok {
+		log.Printf("Info: Resuming %s at offset %d from %s\n", path, offset, a.state.path)
+	}
+	return offset, ok
+}
+
+// updateState records the offsets of the watched files, up to which they were output, so a partial
+// last line not output yet is read again by the next run, and the last --seqno sequence number,
+// and compacts the entries of the others:
+// a file that is gone is dropped once it was last watched longer than --state-ttl ago, and has
+// been found gone for stateRotationGrace since. Files that exist, or that the source cannot tell about,
+// are kept. Only the files of entries past the TTL are looked at, and without holding the lock, as
+// each is a round trip with an SFTP source. It returns the number of entries dropped.
+// It is called by the polling goroutine, which owns the offsets and is the only one changing entries.
+func (a *app) updateState(now time.Time) (dropped int) {
+	s := a.state
+	var stale []string
+	s.mu.Lock()
+	if a.seqno {
+		s.seq = a.seq.Load()
+	}
+	watched := make(map[string]bool)
+	a.watchedFiles.Range(func(key, value interface{}) bool {
+		path, state := key.(string), value.(*fileState)
+		s.entries[path] = &stateEntry{Offset: state.outputOffset(), ID: state.id, Seen: now}
+		watched[path] = true
+		return true
+	})
+	for path, e := range s.entries {
+		if !watched[path] && s.ttl > 0 && now.Sub(e.Seen) >= s.ttl {
+			stale = append(stale, path)
+		}
+	}
+	s.mu.Unlock()
+
+	errs := make(map[string]error, len(stale))
+	for _, path := range stale {
+		_, errs[path] = a.source.Stat(path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, err := range errs {
+		e := s.entries[path]
+		switch {
+		case err == nil:
+			e.Missing = time.Time{}
+		case !os.IsNotExist(err):
+		case e.Missing.IsZero():
+			e.Missing = now
+		case now.Sub(e.Missing) >= stateRotationGrace:
+			delete(s.entries, path)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// saveState updates the --state-file entries, see updateState, and writes them to it,
+// replacing it as a whole so a crash does not leave it half written.
+func (a *app) saveState(now time.Time) {
+	if dropped := a.updateState(now); dropped > 0 {
+		log.Printf("Info: Dropped %d entries of files gone for longer than --state-ttl %v from %s.\n", dropped, a.stateTTL, a.state.path)
+	}
+	a.state.mu.Lock()
+	data, err := json.MarshalIndent(stateContent{Seq: a.state.seq, Files: a.state.entries}, "", "  ")
+	a.state.mu.Unlock()
+	if err == nil {
+		tmp := a.state.path + ".tmp"
+		if err = os.WriteFile(tmp, append(data, '\n'), 0o644); err == nil {
+			err = os.Rename(tmp, a.state.path)
+		}
+	}
+	if err != nil {
+		a.logError("saving state file %s: %v\n", a.state.path, err)
+	}
+}
+
+// saveStateDue saves the --state-file if --state-interval passed since the last save.
+func (a *app) saveStateDue(now time.Time) {
+	if a.state == nil || now.Sub(a.lastStateSave) < a.stateInterval {
+		return
+	}
+	a.lastStateSave = now
+	a.saveState(now)
+}
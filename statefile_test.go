@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestStateResume(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	path, stateFile := filepath.Join(dir, "app.log"), filepath.Join(stateDir, "ftail.state")
+	arguments := []string{"--state-file", stateFile, filepath.Join(dir, "*.log")}
+	writeFile(t, path, "old\n", true)
+
+	tt := newTestTail(t, arguments...)
+	writeFile(t, path, "one\n", false)
+	if got, want := tt.poll(), "one\n"; got != want {
+		t.Fatalf("output %q, want %q", got, want)
+	}
+	tt.a.saveState(time.Now())
+
+	// What was written while ftail was not running is read by the next run.
+	writeFile(t, path, "two\n", false)
+	tt = newTestTail(t, arguments...)
+	if got, want := tt.poll(), "two\n"; got != want {
+		t.Errorf("output %q after resuming, want %q\nlogs:\n%s", got, want, tt.logs.String())
+	}
+
+	// A file replaced since is not resumed, but read from the end like any other.
+	tt.a.saveState(time.Now())
+	writeFile(t, path+".new", "a new file, larger than the old one\n", true)
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatal(err)
+	}
+	tt = newTestTail(t, arguments...)
+	writeFile(t, path, "three\n", false)
+	if got, want := tt.poll(), "three\n"; got != want {
+		t.Errorf("output %q for a replaced file, want %q", got, want)
+	}
+}
+
+func TestStateResumePartialLine(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	arguments := []string{"--line-buffered", "--state-file", filepath.Join(stateDir, "ftail.state"), path}
+	writeFile(t, path, "", true)
+
+	tt := newTestTail(t, arguments...)
+	writeFile(t, path, "a\nb", false)
+	if got, want := tt.poll(), "a\n"; got != want {
+		t.Fatalf("output %q, want %q", got, want)
+	}
+	// Exiting on a signal saves the state without flushing the partial lines.
+	tt.a.finishOutput()
+
+	tt = newTestTail(t, arguments...)
+	writeFile(t, path, "c\n", false)
+	if got, want := tt.poll(), "bc\n"; got != want {
+		t.Errorf("output %q after resuming, want %q", got, want)
+	}
+}
+
+func TestStateResumeSeqno(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	arguments := []string{"--seqno", "--state-file", filepath.Join(stateDir, "ftail.state"), path}
+	writeFile(t, path, "", true)
+
+	tt := newTestTail(t, arguments...)
+	writeFile(t, path, "a\nb\n", false)
+	if got, want := tt.poll(), "1 a\n2 b\n"; got != want {
+		t.Fatalf("output %q, want %q", got, want)
+	}
+	tt.a.finishOutput()
+
+	// The numbers continue after the last one, rather than repeat those already output.
+	tt = newTestTail(t, arguments...)
+	writeFile(t, path, "c\n", false)
+	if got, want := tt.poll(), "3 c\n"; got != want {
+		t.Errorf("output %q after resuming, want %q", got, want)
+	}
+}
+
+func TestStateUnreadable(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "ftail.state")
+	const corrupt = `{"files": {`
+	if err := os.WriteFile(stateFile, []byte(corrupt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a := localApp(&args{stateFile: stateFile})
+	if err := a.openState(); err == nil {
+		t.Fatal("no error for a corrupt state file")
+	}
+	// It is left for the user to look at, not replaced by a save.
+	if data, err := os.ReadFile(stateFile); err != nil || string(data) != corrupt {
+		t.Errorf("state file %q, %v after the error, want it unchanged", data, err)
+	}
+}
+
+func TestStateCompaction(t *testing.T) {
+	dir, stateDir := t.TempDir(), t.TempDir()
+	stateFile := filepath.Join(stateDir, "ftail.state")
+	path, other := filepath.Join(dir, "app.log"), filepath.Join(dir, "other.txt")
+	writeFile(t, path, "", true)
+	writeFile(t, other, "", true)
+
+	// Entries left by an earlier run, for files that are not watched now.
+	now := time.Now()
+	long := now.Add(-48 * time.Hour)
+	earlier := stateContent{Files: map[string]*stateEntry{
+		// It still exists, so it is kept however long ago it was watched.
+		other: {Seen: long},
+		// It has been gone for long enough.
+		filepath.Join(dir, "gone.log"): {Seen: long, Missing: long},
+		// It was watched within the TTL.
+		filepath.Join(dir, "recent.log"): {Seen: now.Add(-time.Hour), Missing: now.Add(-time.Hour)},
+	}}
+	data, err := json.Marshal(earlier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stateFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := newTestTail(t, "--state-file", stateFile, "--state-ttl", "24h", filepath.Join(dir, "*.log"))
+	saved := func(at time.Time) []string {
+		t.Helper()
+		tt.a.saveState(at)
+		s, err := loadState(stateFile, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for p := range s.entries {
+			rel, _ := filepath.Rel(dir, p)
+			paths = append(paths, rel)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+	check := func(step string, got []string, want ...string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: entries %v, want %v", step, got, want)
+		}
+	}
+
+	check("first save", saved(now), "app.log", "other.txt", "recent.log")
+
+	// A file gone past the TTL is kept for the grace period from when it was found gone,
+	// as a rotation may create it again. recent.log is past the TTL by now.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	tt.poll()
+	later := now.Add(25 * time.Hour)
+	check("found gone", saved(later), "app.log", "other.txt")
+	check("within the grace period", saved(later.Add(stateRotationGrace/2)), "app.log", "other.txt")
+
+	// Created again during the grace period, it is no longer gone.
+	writeFile(t, path, "", true)
+	check("created again", saved(later.Add(stateRotationGrace*3/4)), "app.log", "other.txt")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	check("gone again", saved(later.Add(stateRotationGrace)), "app.log", "other.txt")
+	check("after the grace period", saved(later.Add(2*stateRotationGrace)), "other.txt")
+}
+
+// statSource is a localSource calling stat for each Stat.
+type statSource struct {
+	localSource
+	stat func(path string)
+}
+
+// Stat implements fileSource.
+func (s statSource) Stat(path string) (os.FileInfo, error) {
+	s.stat(path)
+	return s.localSource.Stat(path)
+}
+
+func TestStateCompactionStats(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	a := localApp(&args{})
+	a.state = &stateStore{path: filepath.Join(dir, "ftail.state"), ttl: 24 * time.Hour, entries: map[string]*stateEntry{
+		filepath.Join(dir, "recent.log"): {Seen: now.Add(-time.Hour)},
+		filepath.Join(dir, "old.log"):    {Seen: now.Add(-48 * time.Hour)},
+	}}
+	var statted []string
+	a.source = statSource{stat: func(path string) {
+		// Stat may take a round trip, during which files being added look up their offsets.
+		if !a.state.mu.TryLock() {
+			t.Errorf("Stat of %s with the state locked", path)
+		} else {
+			a.state.mu.Unlock()
+		}
+		rel, _ := filepath.Rel(dir, path)
+		statted = append(statted, rel)
+	}}
+
+	// Only the file of the entry past the TTL can be dropped, so only it is looked at.
+	a.updateState(now)
+	if want := []string{"old.log"}; !reflect.DeepEqual(statted, want) {
+		t.Errorf("Stat of %v, want %v", statted, want)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// statusInterval is how often --status-line updates the throughput it shows.
+const statusInterval = time.Second
+
+// statusLine is the single line --status-line keeps updated in place at the bottom of the terminal,
+// on standard error. The content and the log messages share the terminal with it, so it is erased
+// before either is written, see statusClearer, and drawn again at the end of the poll cycle.
+type statusLine struct {
+	mu sync.Mutex
+	w  io.Writer
+	// shown is true while the line is on the terminal, and text is what it shows.
+	shown bool
+	text  string
+	// lines and bytes are emitted since since, and lastFile is the file emitted last.
+	lines, bytes int64
+	since        time.Time
+	lastFile     string
+	// linesPerSec and bytesPerSec are the throughput over the last statusInterval.
+	linesPerSec, bytesPerSec float64
+}
+
+// newStatusLine creates a status line written to w.
+func newStatusLine(w io.Writer) *statusLine {
+	return &statusLine{w: w, since: time.Now()}
+}
+
+// record counts content of a file emitted.
+func (s *statusLine) record(path string, lines, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lines += int64(lines)
+	s.bytes += int64(n)
+	s.lastFile = path
+}
+
+// render draws the line with the number of watched files, updating the throughput every statusInterval.
+func (s *statusLine) render(now time.Time, watched int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elapsed := now.Sub(s.since); elapsed >= statusInterval {
+		s.linesPerSec = float64(s.lines) / elapsed.Seconds()
+		s.bytesPerSec = float64(s.bytes) / elapsed.Seconds()
+		s.lines, s.bytes, s.since = 0, 0, now
+	}
+	text := fmt.Sprintf("%d files | %.0f lines/s | %s/s", watched, s.linesPerSec, formatBytes(s.bytesPerSec))
+	if s.lastFile != "" {
+		text += " | last: " + s.lastFile
+	}
+	text = truncateStatus(text, statusWidth())
+	if s.shown && text == s.text {
+		return
+	}
+	s.text = text
+	_, _ = io.WriteString(s.w, "\r\x1b[K"+text)
+	s.shown = true
+}
+
+// clear erases the line from the terminal until the next render.
+func (s *statusLine) clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clearLocked()
+}
+
+// clearLocked is clear with s.mu held.
+func (s *statusLine) clearLocked() {
+	if s.shown {
+		_, _ = io.WriteString(s.w, "\r\x1b[K")
+		s.shown = false
+	}
+}
+
+// statusClearer is a writer to the terminal that erases the status line before each write,
+// so the content and the log messages do not end up on the same line as it.
+type statusClearer struct {
+	w      io.Writer
+	status *statusLine
+}
+
+// Write implements io.Writer.
+func (c statusClearer) Write(p []byte) (int, error) {
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	c.status.clearLocked()
+	return c.w.Write(p)
+}
+
+// statusWidth returns the number of columns the status line may use: one less than $COLUMNS,
+// or than 80 if it is not set, so the line never wraps and can be redrawn with a carriage return.
+func statusWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 1 {
+		return n - 1
+	}
+	return 79
+}
+
+// truncateStatus shortens text to width runes, ending it with an ellipsis.
+func truncateStatus(text string, width int) string {
+	r := []rune(text)
+	if len(r) <= width {
+		return text
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// formatBytes formats a number of bytes with a binary unit, e.g. 1.5KiB.
+func formatBytes(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0fB", n)
+	}
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%ciB", n, units[i])
+}
+
+// statusLineEnabled tells whether --status-line is drawn: only if standard output and standard
+// error are both terminals, as the line would corrupt redirected output or logs.
+func (a *app) statusLineEnabled() bool {
+	return a.statusLine && a.output == "" && a.listenUnix == "" && isTerminal(os.Stdout) && isTerminal(os.Stderr)
+}
+
+// renderStatus draws the --status-line, if it is enabled.
+func (a *app) renderStatus(now time.Time) {
+	if a.status == nil {
+		return
+	}
+	watched := 0
+	a.watchedFiles.Range(func(_, _ interface{}) bool {
+		watched++
+		return true
+	})
+	a.status.render(now, watched)
+}
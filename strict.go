@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// logError logs an error that ftail recovers from by default, e.g. by retrying at the next poll.
+// With --strict, the first such error stops ftail instead: run returns it, and ftail exits with a nonzero status.
+//
+// The errors treated this way are those that do not resolve by themselves: malformed glob patterns
+// and other walk errors, failing to create or add to the directory watcher, watcher errors, failing to
+// stat, open, or read a watched file for another reason than it being removed (e.g. permission denied),
+// and failing to serve --serve. Expected conditions are still tolerated: files being removed, truncated,
+// or replaced, directories over --max-dir-watches, and invalid configurations on reload.
+func (a *app) logError(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	log.Print("Error: " + msg)
+	if !a.strict {
+		return
+	}
+	a.fatalOnce.Do(func() {
+		a.fatalErr = fmt.Errorf("stopping on the first error (--strict): %s", strings.TrimSuffix(msg, "\n"))
+		a.cancel()
+	})
+}
+
+// errStrictWatcher is returned with --strict if the directory watcher cannot be created.
+var errStrictWatcher = errors.New("cannot create the directory watcher (--strict does not fall back to --poll-only)")
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// tailBytesOffset returns where --tail-bytes starts reading a file found at startup: the first line
+// starting in its last --tail-bytes bytes, so no line is output from its middle. A file no larger
+// is read from the start, and one without a line starting there from its end.
+func (a *app) tailBytesOffset(path string, size int64) int64 {
+	start := size - int64(a.tailBytes)
+	if start <= 0 {
+		return 0
+	}
+	file, err := a.openFile(path)
+	if err != nil {
+		return size
+	}
+	defer a.closeFile(file)
+	// A line starts at start if the previous byte ends a record.
+	from := max(start-int64(len(a.delim)), 0)
+	r := bufio.NewReader(io.NewSectionReader(file, from, size-from))
+	var window []byte
+	for offset := from; ; offset++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return size
+		}
+		window = append(window, c)
+		if len(window) > len(a.delim) {
+			window = window[1:]
+		}
+		if offset+1 >= start && bytes.Equal(window, a.delim) {
+			return offset + 1
+		}
+	}
+}
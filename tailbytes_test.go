@@ -0,0 +1,47 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTailBytes(t *testing.T) {
+	const content = "aaaa\nbbbb\ncccc\n"
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		args    []string
+		want    string
+	}{
+		{"larger than the file", content, 100, nil, content},
+		{"size of the file", content, len(content), nil, content},
+		{"at a line start", content, 10, nil, "bbbb\ncccc\n"},
+		{"right after a newline", content, 5, nil, "cccc\n"},
+		{"in the middle of a line", content, 7, nil, "cccc\n"},
+		{"in the last line", content, 3, nil, ""},
+		{"no line starts", "aaaa\nbbbb", 3, nil, ""},
+		{"delimiter of several bytes", "aa\r\nbb\r\ncc\r\n", 5, []string{"--record-delimiter", `\r\n`}, "cc\r\n"},
+		{"in the middle of a delimiter", "aa\r\nbb\r\ncc\r\n", 7, []string{"--record-delimiter", `\r\n`}, "cc\r\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "app.log")
+			writeFile(t, path, tc.content, true)
+			args := append([]string{"--tail-bytes", strconv.Itoa(tc.n)}, tc.args...)
+			tt := newTestTail(t, append(args, filepath.Join(dir, "*.log"))...)
+			if got := tt.poll(); got != tc.want {
+				t.Errorf("output %q, want %q", got, tc.want)
+			}
+
+			// Content appended later is read as usual.
+			more := "more" + string(tt.a.delim)
+			writeFile(t, path, more, false)
+			if got := tt.poll(); got != more {
+				t.Errorf("appended: output %q, want %q", got, more)
+			}
+		})
+	}
+}
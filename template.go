@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"text/template"
+	"time"
+)
+
+// templateFormatter renders each line with the --output-template, a Go text/template.
+// Unlike the --format formatters, it is applied when a line is selected for output,
+// where its line number and pattern are known, see outputLine.
+type templateFormatter struct {
+	t *template.Template
+}
+
+// templateLine holds the fields available to an --output-template.
+type templateLine struct {
+	// File is the path as shown in the output, see displayPath, and RealPath the real path of the file.
+	File     string
+	RealPath string
+	// Line is the content of the line, without its trailing newline.
+	Line string
+	// Time is when the line was output.
+	Time time.Time
+	// LineNo is the number of the line in the file, counted from where ftail started reading it.
+	LineNo int
+	// Seq is the --seqno sequence number, or 0 without --seqno.
+	Seq uint64
+	// Tag is the glob pattern that matched the file.
+	Tag string
+	// FileID is the identity of the file with --emit-file-id, or empty.
+	FileID string
+	// Offset and Length are where the line is in the file with --emit-offsets, or 0.
+	Offset int64
+	Length int
+	// LogTime is the timestamp of the line with --auto-timestamp, or zero if it has none.
+	LogTime time.Time
+}
+
+// newTemplateFormatter compiles an --output-template. It is also executed once on sample data,
+// so unknown fields are reported at startup rather than at the first line.
+func newTemplateFormatter(text string) (*templateFormatter, error) {
+	t, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --output-template: %w", err)
+	}
+	if err := t.Execute(io.Discard, templateLine{}); err != nil {
+		return nil, fmt.Errorf("invalid --output-template: %w", err)
+	}
+	return &templateFormatter{t: t}, nil
+}
+
+// Format implements formatter. A newline is added unless the template output ends with one.
+func (f *templateFormatter) Format(rec record) ([]byte, error) {
+	var b bytes.Buffer
+	err := f.t.Execute(&b, templateLine{
+		File:     rec.File,
+		RealPath: rec.RealPath,
+		Line:     string(rec.Line),
+		Time:     rec.Time,
+		LineNo:   rec.LineNo,
+		Seq:      rec.Seq,
+		Tag:      rec.Tag,
+		FileID:   rec.FileID,
+		Offset:   rec.Offset,
+		Length:   rec.Length,
+		LogTime:  rec.LogTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// looksLikeText reports whether the first bytes of a file look like text: no NUL bytes,
+// and at most one in ten bytes are control characters or invalid UTF-8.
+// A rune cut off at the end of the sample is not counted as invalid.
+func looksLikeText(sample []byte) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+	bad := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRune(sample[i:])
+		switch {
+		case r == utf8.RuneError && size <= 1:
+			if !utf8.FullRune(sample[i:]) {
+				i = len(sample)
+				continue
+			}
+			bad++
+		case r < ' ' && r != '\t' && r != '\n' && r != '\r' && r != '\f' && r != '\v' && r != '\b' && r != 0x1b:
+			bad++
+		}
+		i += size
+	}
+	return bad*10 <= len(sample)
+}
+
+// sniffText reports whether the first --sniff-bytes of file look like text, see looksLikeText.
+// known is false for an empty file, whose nature cannot be told yet.
+func (a *app) sniffText(file io.ReaderAt) (text, known bool, err error) {
+	sample := make([]byte, a.sniffBytes)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, false, err
+	}
+	if n == 0 {
+		return false, false, nil
+	}
+	return looksLikeText(sample[:n]), true, nil
+}
+
+// textOnlySkip tells, with --text-only, whether a file to be watched does not look like text.
+// Files that cannot be sniffed, e.g. empty ones, are watched, and sniffed once they are read
+// from the start, see pollFile.
+func (a *app) textOnlySkip(realPath string) bool {
+	if !a.textOnly {
+		return false
+	}
+	file, err := a.openFile(realPath)
+	if err != nil {
+		return false
+	}
+	defer a.closeFile(file)
+	text, known, err := a.sniffText(file)
+	return err == nil && known && !text
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// timestampSniffBytes is how much of the start of a file --auto-timestamp samples.
+const timestampSniffBytes = 4096
+
+// timestampFormat is a common timestamp format of log lines, recognized by --auto-timestamp.
+type timestampFormat struct {
+	name string
+	// re finds the timestamp in a line, as its first capture group.
+	re *regexp.Regexp
+	// parse parses the timestamp found, relative to now for formats without a year.
+	parse func(s string, now time.Time) (time.Time, error)
+}
+
+// timestampFormats are the formats --auto-timestamp tries, in this order.
+var timestampFormats = []*timestampFormat{
+	{
+		name: "iso8601",
+		re:   regexp.MustCompile(`(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?)`),
+		parse: func(s string, _ time.Time) (time.Time, error) {
+			s = strings.Replace(strings.Replace(s, " ", "T", 1), ",", ".", 1)
+			for _, layout := range []string{"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999Z0700"} {
+				if t, err := time.Parse(layout, s); err == nil {
+					return t, nil
+				}
+			}
+			return time.ParseInLocation("2006-01-02T15:04:05.999999999", s, time.Local)
+		},
+	},
+	{
+		// Apache and nginx access logs, e.g. [10/Oct/2026:13:55:36 -0700].
+		name: "clf",
+		re:   regexp.MustCompile(`\[(\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`),
+		parse: func(s string, _ time.Time) (time.Time, error) {
+			return time.Parse("02/Jan/2006:15:04:05 -0700", s)
+		},
+	},
+	{
+		// nginx error logs, e.g. 2026/10/10 13:55:36.
+		name: "nginx-error",
+		re:   regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2})`),
+		parse: func(s string, _ time.Time) (time.Time, error) {
+			return time.ParseInLocation("2006/01/02 15:04:05", s, time.Local)
+		},
+	},
+	{
+		// BSD syslog, e.g. Oct 10 13:55:36, which has no year: it is the one placing it closest to now.
+		name: "syslog",
+		re:   regexp.MustCompile(`^(?:<\d+>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})`),
+		parse: func(s string, now time.Time) (time.Time, error) {
+			t, err := time.ParseInLocation("Jan _2 15:04:05", s, time.Local)
+			if err != nil {
+				return t, err
+			}
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			return t, nil
+		},
+	},
+}
+
+// detectTimestamp returns the format of the timestamps of most of the lines of a sample, or nil if
+// no format matches at least half of them.
+func detectTimestamp(sample, delim []byte) *timestampFormat {
+	lines := bytes.Split(sample, delim)
+	// The last line may be cut off.
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	var nonEmpty int
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 {
+			nonEmpty++
+		}
+	}
+	if nonEmpty == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, f := range timestampFormats {
+		matched := 0
+		for _, line := range lines {
+			if _, ok := f.find(line, now); ok {
+				matched++
+			}
+		}
+		if 2*matched >= nonEmpty {
+			return f
+		}
+	}
+	return nil
+}
+
+// find returns the timestamp of a line, or ok false if it has none in the format.
+func (f *timestampFormat) find(line []byte, now time.Time) (t time.Time, ok bool) {
+	m := f.re.FindSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := f.parse(string(m[1]), now)
+	return t, err == nil
+}
+
+// sniffTimestamp detects the timestamp format of a file from its first lines, for --auto-timestamp.
+func (a *app) sniffTimestamp(path string, state *fileState) {
+	if !a.autoTimestamp {
+		return
+	}
+	file, err := a.openFile(path)
+	if err != nil {
+		return
+	}
+	defer a.closeFile(file)
+	sample := make([]byte, timestampSniffBytes)
+	n, err := file.ReadAt(sample, 0)
+	if err != nil && !errors.Is(err, io.EOF) || n == 0 {
+		return
+	}
+	a.setTimestampFormat(path, state, detectTimestamp(sample[:n], a.delim))
+}
+
+// setTimestampFormat records the detected timestamp format of a file.
+func (a *app) setTimestampFormat(path string, state *fileState, f *timestampFormat) {
+	ls := &state.lines
+	ls.stampDetected, ls.stamp = true, f
+	if f != nil {
+		log.Printf("Info: Detected %s timestamps in %s\n", f.name, path)
+	}
+}
+
+// lineStamp returns the timestamp of a complete line of a file with --auto-timestamp, or zero if it has
+// none. The format of a file that was empty when added, or was replaced since, is detected from
+// the lines read then: the first one with a timestamp in a known format decides, and the file
+// has none if there is none in its first timestampSniffBytes.
+func (a *app) lineStamp(path string, state *fileState, line []byte) time.Time {
+	if !a.autoTimestamp {
+		return time.Time{}
+	}
+	ls := &state.lines
+	if !ls.stampDetected {
+		ls.stampSniffed += len(line)
+		f := detectTimestamp(line, a.delim)
+		if f == nil && ls.stampSniffed < timestampSniffBytes {
+			return time.Time{}
+		}
+		a.setTimestampFormat(path, state, f)
+	}
+	if ls.stamp == nil {
+		return time.Time{}
+	}
+	t, _ := ls.stamp.find(a.lineContent(line), time.Now())
+	return t
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// traceTimeLayout is the timestamp of --trace-events messages, finer than the one of the logger,
+// as events often come in bursts.
+const traceTimeLayout = "15:04:05.000000"
+
+// tracef logs a --trace-events message, with the time it was made.
+func (a *app) tracef(format string, args ...interface{}) {
+	if a.traceEvents {
+		log.Printf("Debug: Trace %s: "+format, append([]interface{}{time.Now().Format(traceTimeLayout)}, args...)...)
+	}
+}
+
+// traceEvent logs a raw event of the directory watcher, and which of its operations --watch-ops
+// lets it act on. Writes to files no pattern matches are left out, so tracing to a file
+// in a watched directory does not trace its own writes.
+func (a *app) traceEvent(event fsnotify.Event) {
+	if !a.traceEvents {
+		return
+	}
+	if _, ok := a.globMatch(event.Name); ok || event.Op != fsnotify.Write {
+		a.tracef("event %s %s (acting on %s)\n", event.Op, event.Name, event.Op&a.watchOpMask)
+	}
+}
+
+// traceWalkNote logs every walk decision of a scan, including those logWalkNote does not repeat
+// or leaves out, and passes it on to logWalkNote.
+func (a *app) traceWalkNote(n walkNote) {
+	if n.skipped {
+		a.tracef("scan skips %s (pattern %s): %s\n", n.path, n.pattern, n.reason)
+	} else {
+		a.tracef("scan notes %s (pattern %s): %s\n", n.path, n.pattern, n.reason)
+	}
+	a.logWalkNote(n)
+}
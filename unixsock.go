@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixOutputPrefix marks an --output that is a Unix domain socket to connect to, e.g. unix:///run/ftail.sock.
+const unixOutputPrefix = "unix://"
+
+// Bounds of the delay between attempts to connect to an --output socket.
+const (
+	unixDialMinBackoff = 100 * time.Millisecond
+	unixDialMaxBackoff = 10 * time.Second
+	unixDialTimeout    = time.Second
+)
+
+// unixConsumerQueueSize is the number of writes queued for a consumer of --listen-unix.
+// Writes to a consumer whose queue is full are dropped for it.
+const unixConsumerQueueSize = 256
+
+// unixClient writes the output to a Unix domain socket, for --output unix://PATH. It connects
+// lazily and reconnects with exponential backoff after the connection fails. Output written while
+// disconnected is dropped and counted. Write never returns an error, so the buffer in front of it
+// keeps working once the connection is back. A consumer that stops reading blocks the writes,
+// which --output-timeout guards against.
+type unixClient struct {
+	path string
+	conn net.Conn
+	// retryAt is when to try to connect next, and backoff the delay after the next failure.
+	retryAt time.Time
+	backoff time.Duration
+	// dropped counts the bytes dropped since the connection failed.
+	dropped int64
+	// failed is true once connecting failed or the connection was lost, until connected again.
+	failed bool
+}
+
+// newUnixClient creates a client for the socket at path. It does not connect yet.
+func newUnixClient(path string) *unixClient {
+	return &unixClient{path: path, backoff: unixDialMinBackoff}
+}
+
+// Write implements io.Writer.
+func (c *unixClient) Write(p []byte) (int, error) {
+	if c.conn == nil && !c.connect() {
+		c.dropped += int64(len(p))
+		return len(p), nil
+	}
+	if _, err := c.conn.Write(p); err != nil {
+		log.Printf("Warning: lost the connection to %s: %v; reconnecting\n", c.path, err)
+		_ = c.conn.Close()
+		c.conn, c.failed = nil, true
+		c.retryAt = time.Now().Add(c.backoff)
+		c.dropped += int64(len(p))
+	}
+	return len(p), nil
+}
+
+// connect tries to connect to the socket, unless it is too early after the last failure.
+func (c *unixClient) connect() bool {
+	now := time.Now()
+	if now.Before(c.retryAt) {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", c.path, unixDialTimeout)
+	if err != nil {
+		if !c.failed {
+			log.Printf("Warning: cannot connect to %s: %v; retrying with backoff and dropping output meanwhile\n", c.path, err)
+			c.failed = true
+		}
+		c.retryAt = now.Add(c.backoff)
+		c.backoff = min(2*c.backoff, unixDialMaxBackoff)
+		return false
+	}
+	if c.failed {
+		log.Printf("Info: Connected to %s after dropping %d bytes of output.\n", c.path, c.dropped)
+	}
+	c.conn, c.failed, c.dropped, c.backoff = conn, false, 0, unixDialMinBackoff
+	return true
+}
+
+// Close closes the connection, if any.
+func (c *unixClient) Close() error {
+	if c.dropped > 0 {
+		log.Printf("Warning: %d bytes of output were not delivered to %s.\n", c.dropped, c.path)
+	}
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// fileModeFlag is a flag holding permission bits in octal, e.g. 0660.
+type fileModeFlag fs.FileMode
+
+// String returns the mode in octal.
+func (m *fileModeFlag) String() string {
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+// Set parses an octal mode.
+func (m *fileModeFlag) Set(v string) error {
+	n, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || n > 0o777 {
+		return errors.New("expected permission bits in octal, e.g. 0660")
+	}
+	*m = fileModeFlag(n)
+	return nil
+}
+
+// unixListener serves the output to every consumer connected to a Unix domain socket, for --listen-unix.
+// Each consumer has its own bounded queue and writer goroutine, so a slow consumer only loses
+// its own output, which is counted and logged when it disconnects. Output written while no consumer
+// is connected is discarded.
+type unixListener struct {
+	path      string
+	ln        net.Listener
+	mu        sync.Mutex
+	consumers map[*unixConsumer]struct{}
+	// wg tracks the goroutines writing to the consumers, and closed is set by Close.
+	wg     sync.WaitGroup
+	closed bool
+}
+
+// unixConsumer is a connection to a --listen-unix socket.
+type unixConsumer struct {
+	conn    net.Conn
+	chunks  chan []byte
+	dropped int
+}
+
+// listenUnix creates the socket at path with the given permissions, replacing a stale socket left there.
+func listenUnix(path string, mode fs.FileMode) (*unixListener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("setting the permissions of %s: %w", path, err)
+	}
+	l := &unixListener{path: path, ln: ln, consumers: make(map[*unixConsumer]struct{})}
+	go l.accept()
+	log.Printf("Info: Serving the output at %s\n", path)
+	return l, nil
+}
+
+// accept registers the consumers connecting until the listener is closed.
+func (l *unixListener) accept() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("Error: accepting a connection on %s: %v\n", l.path, err)
+			}
+			return
+		}
+		u := &unixConsumer{conn: conn, chunks: make(chan []byte, unixConsumerQueueSize)}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		l.consumers[u] = struct{}{}
+		l.wg.Add(1)
+		l.mu.Unlock()
+		go l.serve(u)
+	}
+}
+
+// serve writes the queued output to a consumer until it disconnects or the listener is closed.
+func (l *unixListener) serve(u *unixConsumer) {
+	defer l.wg.Done()
+	defer func() { _ = u.conn.Close() }()
+	for chunk := range u.chunks {
+		if _, err := u.conn.Write(chunk); err != nil {
+			break
+		}
+	}
+	l.mu.Lock()
+	if _, ok := l.consumers[u]; ok {
+		delete(l.consumers, u)
+		close(u.chunks)
+	}
+	dropped := u.dropped
+	l.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("Warning: %d writes were dropped for a slow consumer of %s.\n", dropped, l.path)
+	}
+}
+
+// Write implements io.Writer, queuing a copy of p for every consumer. It never returns an error.
+func (l *unixListener) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.consumers) == 0 {
+		return len(p), nil
+	}
+	chunk := append([]byte(nil), p...)
+	for u := range l.consumers {
+		select {
+		case u.chunks <- chunk:
+		default:
+			u.dropped++
+		}
+	}
+	return len(p), nil
+}
+
+// Close stops accepting consumers, gives the connected ones up to unixDialTimeout to receive
+// what is queued for them, and removes the socket.
+func (l *unixListener) Close() error {
+	err := l.ln.Close()
+	l.mu.Lock()
+	l.closed = true
+	for u := range l.consumers {
+		delete(l.consumers, u)
+		close(u.chunks)
+	}
+	l.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(unixDialTimeout):
+	}
+	_ = os.Remove(l.path)
+	return err
+}
+
+// unixOutputPath returns the socket path of an --output unix://PATH, or ok false for a file.
+func unixOutputPath(output string) (path string, ok bool) {
+	return strings.CutPrefix(output, unixOutputPrefix)
+}
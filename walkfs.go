@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// loopSafeFS wraps the discovery filesystem of a pattern's base directory for globWalk.
+// doublestar follows symlinked directories for `**`, so a link pointing back at one of its
+// own ancestors would make the walk descend until the path gets too long. loopSafeFS reports
+// such directories as empty, so the walk terminates and each loop is reported once.
+type loopSafeFS struct {
+	fs.FS
+	// base is the directory the FS is rooted at, and discovery the one it is from.
+	base      string
+	discovery discovery
+	// realPaths caches the resolved path of each directory name, or "" if it cannot be resolved.
+	realPaths map[string]string
+	// reported records the directory names whose loop has been passed to onLoop.
+	reported map[string]bool
+	// onLoop is called once for each directory name found to be a symlink loop.
+	onLoop func(name string)
+	// unavailable is the first error of the discovery not being reachable. The walk skips
+	// the directories it could not read, so it is incomplete then.
+	unavailable error
+}
+
+// newLoopSafeFS creates a loopSafeFS rooted at base of d.
+func newLoopSafeFS(d discovery, base string, onLoop func(name string)) *loopSafeFS {
+	return &loopSafeFS{
+		FS:        d.DirFS(base),
+		base:      base,
+		discovery: d,
+		realPaths: make(map[string]string),
+		reported:  make(map[string]bool),
+		onLoop:    onLoop,
+	}
+}
+
+// ReadDir reads a directory, unless it is a symlink loop, which is reported as empty.
+func (l *loopSafeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." && l.isLoop(name) {
+		if !l.reported[name] {
+			l.reported[name] = true
+			l.onLoop(name)
+		}
+		return nil, nil
+	}
+	entries, err := fs.ReadDir(l.FS, name)
+	l.noteUnavailable(err)
+	return entries, err
+}
+
+// Stat forwards to the wrapped FS.
+func (l *loopSafeFS) Stat(name string) (fs.FileInfo, error) {
+	fi, err := fs.Stat(l.FS, name)
+	l.noteUnavailable(err)
+	return fi, err
+}
+
+// noteUnavailable records err if it is the first one of the discovery not being reachable.
+func (l *loopSafeFS) noteUnavailable(err error) {
+	if l.unavailable == nil && errors.Is(err, errSourceUnavailable) {
+		l.unavailable = err
+	}
+}
+
+// isLoop reports whether the directory name resolves to its parent directory or one of its ancestors,
+// or cannot be resolved because of too many levels of symlinks.
+func (l *loopSafeFS) isLoop(name string) bool {
+	realDir, ok := l.realPath(name)
+	if !ok {
+		return realDir == reasonSymlinkLoop
+	}
+	realParent, ok := l.realPath(path.Dir(name))
+	if !ok {
+		return false
+	}
+	return realDir == realParent || strings.HasPrefix(realParent, realDir+string(filepath.Separator))
+}
+
+// realPath resolves a directory name relative to the base directory. If it cannot be resolved,
+// it returns false and, for symlink loops, reasonSymlinkLoop.
+func (l *loopSafeFS) realPath(name string) (string, bool) {
+	if realDir, ok := l.realPaths[name]; ok {
+		return realDir, realDir != "" && realDir != reasonSymlinkLoop
+	}
+	realDir, err := l.discovery.EvalSymlinks(filepath.Join(l.base, filepath.FromSlash(name)))
+	if err != nil {
+		realDir = ""
+		if symlinkErrorReason(err) == reasonSymlinkLoop {
+			realDir = reasonSymlinkLoop
+		}
+	} else if abs, err := filepath.Abs(realDir); err == nil {
+		realDir = abs
+	}
+	l.realPaths[name] = realDir
+	return realDir, realDir != "" && realDir != reasonSymlinkLoop
+}
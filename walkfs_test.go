@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSymlinkLoops(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "a", "b", "app.log"), "", true)
+	// up points back at an ancestor, and self at itself.
+	if err := os.Symlink("../..", filepath.Join(dir, "a", "b", "up")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink("self.log", filepath.Join(dir, "a", "self.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	tt := newTestTail(t, filepath.Join(dir, "**", "*.log"))
+	var watched []string
+	tt.a.watchedFiles.Range(func(key, _ interface{}) bool {
+		watched = append(watched, key.(string))
+		return true
+	})
+	if want := filepath.Join(dir, "a", "b", "app.log"); len(watched) != 1 || watched[0] != want {
+		t.Errorf("watching %v, want only %s", watched, want)
+	}
+
+	// Further scans do not report the loops again.
+	tt.scan()
+	tt.scan()
+	logs := tt.logs.String()
+	for _, loop := range []string{filepath.Join(dir, "a", "b", "up"), filepath.Join(dir, "a", "self.log")} {
+		if n := strings.Count(logs, "Skipping "+loop+" "); n != 1 {
+			t.Errorf("loop %s reported %d times, want once; logs:\n%s", loop, n, logs)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchOps is the default --watch-ops: every directory event ftail knows.
+const defaultWatchOps = "create,write,remove,rename,chmod"
+
+// watchOpNames are the names --watch-ops accepts.
+var watchOpNames = map[string]fsnotify.Op{
+	"create": fsnotify.Create,
+	"write":  fsnotify.Write,
+	"remove": fsnotify.Remove,
+	"rename": fsnotify.Rename,
+	"chmod":  fsnotify.Chmod,
+}
+
+// parseWatchOps parses the comma-separated operations of --watch-ops.
+func parseWatchOps(ops string) (fsnotify.Op, error) {
+	var mask fsnotify.Op
+	for _, name := range strings.Split(ops, ",") {
+		op, ok := watchOpNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("--watch-ops must list create, write, remove, rename, or chmod, got %q", name)
+		}
+		mask |= op
+	}
+	return mask, nil
+}